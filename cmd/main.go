@@ -4,18 +4,47 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	_ "time/tzdata" // time zones on systems without a zoneinfo database
 
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/kindleclippings"
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/output"
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/prompt"
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/storage"
 )
 
 func main() {
 	inputFile := flag.String("input", "", "Path to My Clippings.txt")
 	outputDir := flag.String("output", "./highlights", "Output directory")
+	strict := flag.Bool("strict", false, "Fail on the first clippings entry that cannot be parsed")
+	configFile := flag.String("config", "", "Path to config file (default "+storage.DefaultConfigPath()+")")
+	deviceName := flag.String("device", "", "Device from the config file")
+	timezone := flag.String("timezone", "", "Time zone of the Kindle, e.g. Europe/Berlin (default local time zone)")
+	rerender := flag.Bool("rerender", false, "Regenerate highlights and managed properties of existing notes from the template")
+	forget := flag.Bool("forget", false, "Export highlights deleted from the notes of the selected books again")
+	similarity := flag.Float64("similarity", 0, fmt.Sprintf("Similarity from 0 to 1 from which an edited highlight in a note counts as the same (default %v)", output.DefaultSimilarity))
+	templateName := flag.String("template", "", "Template set ("+strings.Join(output.TemplateNames(), ", ")+") or path to a template file or directory (default "+output.DefaultTemplate+")")
+	style := flag.String("style", "", "Highlight style ("+strings.Join(output.Styles, ", ")+") (default the one of the template)")
+	transliterate := flag.Bool("transliterate", false, "Write the filenames of new notes in ASCII where possible")
 	help := flag.Bool("help", false, "Show help")
 	flag.Parse()
 
+	cfg, err := storage.ReadConfig(*configFile)
+	if err != nil {
+		fmt.Println("Error reading config file:", err)
+		return
+	}
+
+	device, found := cfg.Device(*deviceName, *inputFile)
+	if *deviceName != "" && !found {
+		fmt.Println("Device not found in config file:", *deviceName)
+		return
+	}
+
+	if *inputFile == "" {
+		*inputFile = device.Input
+	}
+
 	if *inputFile == "" {
 		fmt.Println("Input file is required")
 		flag.PrintDefaults()
@@ -32,12 +61,51 @@ func main() {
 		return
 	}
 
-	books, err := kindleclippings.Parse(*inputFile)
+	if *similarity < 0 || *similarity > 1 {
+		fmt.Println("Similarity must be between 0 and 1")
+		return
+	}
+	if *similarity == 0 {
+		*similarity = cfg.Similarity
+	}
+
+	if *templateName == "" {
+		*templateName = cfg.Template
+	}
+
+	if *style == "" {
+		*style = cfg.Style
+	}
+
+	tmpl, err := output.LoadTemplate(*templateName, *style)
+	if err != nil {
+		fmt.Println("Error loading template:", err)
+		return
+	}
+
+	if *timezone != "" {
+		device.Timezone = *timezone
+	}
+
+	loc, err := cfg.Location(device)
+	if err != nil {
+		fmt.Println("Error loading time zone:", err)
+		return
+	}
+
+	books, report, err := kindleclippings.Parse(*inputFile, kindleclippings.Options{
+		Strict:        *strict,
+		Location:      loc,
+		Transliterate: *transliterate || cfg.Transliterate,
+	})
 	if err != nil {
 		fmt.Println("Error processing kindle clippings from input file:", err)
-		return
+		// with -strict, scripts tell a bad entry by the exit status
+		os.Exit(1)
 	}
 
+	fmt.Println("Input encoding:", report.Encoding)
+
 	userResponse, err := prompt.Run(books)
 	if err != nil {
 		fmt.Println("Error prompting user for books:", err)
@@ -52,9 +120,18 @@ func main() {
 		return
 	}
 
-	err = output.WriteBooks(*outputDir, requestedBooks, existingHighlightsMap)
+	err = output.WriteBooks(*outputDir, requestedBooks, existingHighlightsMap, output.Options{
+		Rerender:   *rerender,
+		Forget:     *forget,
+		Similarity: *similarity,
+		Template:   tmpl,
+	})
 	if err != nil {
 		fmt.Println("Error writing books to output directory:", err)
 		return
 	}
+
+	if summary := report.Summary(); summary != "" {
+		fmt.Print(summary)
+	}
 }
@@ -5,9 +5,10 @@ go 1.22.10
 require (
 	github.com/goodsign/monday v1.0.2
 	github.com/manifoldco/promptui v0.9.0
+	golang.org/x/text v0.14.0
 )
 
 require (
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
-	golang.org/x/sys v0.0.0-20181122145206-62eef0e2fa9b // indirect
+	golang.org/x/sys v0.5.0 // indirect
 )
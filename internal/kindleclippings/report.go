@@ -0,0 +1,85 @@
+package kindleclippings
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/parser"
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/storage"
+)
+
+// reportCategories are the kinds of problems counted by Report, in the
+// order they are listed in the summary.
+var reportCategories = []error{
+	parser.ErrUnknownLanguage,
+	parser.ErrInvalidDate,
+	parser.ErrInvalidMetaInfo,
+}
+
+// Report collects the entries that could not be fully parsed in lenient
+// mode. Entries with ErrInvalidDate are still exported, with a zero date;
+// all other entries are skipped.
+type Report struct {
+	// Encoding is the detected encoding of the input.
+	Encoding storage.Encoding
+	Warnings []*parser.EntryError
+	// LimitReached lists the books that hit the clipping limit.
+	LimitReached []LimitReached
+}
+
+type LimitReached struct {
+	Title string
+	// Count is the number of highlights cut by the limit.
+	Count int
+}
+
+// Count returns the number of warnings wrapping kind.
+func (r *Report) Count(kind error) int {
+	if r == nil {
+		return 0
+	}
+
+	cnt := 0
+	for _, w := range r.Warnings {
+		if errors.Is(w, kind) {
+			cnt++
+		}
+	}
+
+	return cnt
+}
+
+// Summary lists the books that hit the clipping limit and how many entries
+// fell into each category, followed by the warnings themselves.
+func (r *Report) Summary() string {
+	if r == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	if len(r.LimitReached) > 0 {
+		sb.WriteString("Clipping limit reached:\n")
+		for _, l := range r.LimitReached {
+			sb.WriteString(fmt.Sprintf("  %s: %d highlights\n", l.Title, l.Count))
+		}
+	}
+
+	if len(r.Warnings) == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("%d entries with problems:\n", len(r.Warnings)))
+	for _, kind := range reportCategories {
+		if cnt := r.Count(kind); cnt > 0 {
+			sb.WriteString(fmt.Sprintf("  %s: %d\n", kind, cnt))
+		}
+	}
+
+	for _, w := range r.Warnings {
+		sb.WriteString(fmt.Sprintf("- %v\n", w))
+	}
+
+	return sb.String()
+}
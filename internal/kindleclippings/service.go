@@ -3,60 +3,257 @@ package kindleclippings
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/parser"
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/storage"
+	"github.com/nsr888/kindle-highlights-to-obsidian/pkg/filename"
 )
 
-func Parse(inputFile string) (model.Books, error) {
+type Options struct {
+	// Strict fails on the first entry that cannot be fully parsed instead of
+	// collecting it in the Report.
+	Strict bool
+	// Location is the time zone of the device, Kindle timestamps carry none.
+	// Defaults to time.Local.
+	Location *time.Location
+	// Transliterate writes the filenames of the books in ASCII where
+	// possible.
+	Transliterate bool
+}
+
+func Parse(inputFile string, opts Options) (model.Books, *Report, error) {
+	fd, err := os.Open(inputFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open input file: %w", err)
+	}
+	defer fd.Close()
+
+	return ParseReader(fd, opts)
+}
+
+// ParseReader parses My Clippings.txt content entry by entry as it is read
+// from r.
+func ParseReader(r io.Reader, opts Options) (model.Books, *Report, error) {
 	var (
-		books   = make(model.Books, 0)
+		books    = make(model.Books, 0)
 		booksMap = make(map[string]int)
+		notesMap = make(map[int][]parser.HighlightData)
+		report   = &Report{}
 	)
 
 	translMap, err := storage.ReadTranslations()
 	if err != nil {
-		return nil, fmt.Errorf("load translation map: %w", err)
+		return nil, nil, fmt.Errorf("load translation map: %w", err)
 	}
 
-	rawClippings, err := storage.ReadRawClippings(inputFile)
-	if err != nil {
-		return nil, fmt.Errorf("read clippings: %w", err)
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
 	}
 
-	for _, c := range rawClippings {
-		entry, errP := parser.ParseClippingsEntry(c, translMap)
+	clippings := storage.NewClippingsReader(r)
+	for clippings.Next() {
+		c := clippings.Entry()
+		entry, errP := parser.ParseClippingsEntry(c.Text, translMap, loc)
 		if errP != nil {
 			if errors.Is(errP, parser.ErrInvalidEntry) ||
 				errors.Is(errP, parser.ErrEmptyHighlight) {
 				continue
 			}
-			return nil, fmt.Errorf("parse clippings entry: %w", errP)
+
+			var entryErr *parser.EntryError
+			if !errors.As(errP, &entryErr) {
+				return nil, nil, fmt.Errorf("parse clippings entry at line %d: %w", c.Line, errP)
+			}
+			entryErr.Line = c.Line
+
+			if opts.Strict {
+				return nil, nil, fmt.Errorf("parse clippings entry: %w", entryErr)
+			}
+			report.Warnings = append(report.Warnings, entryErr)
+
+			// entries without a date are still worth exporting
+			if !errors.Is(errP, parser.ErrInvalidDate) {
+				continue
+			}
 		}
 
-		key := fmt.Sprintf("%s%s", entry.BookTitle, entry.BookAuthor)
+		key := model.BookID(entry.BookTitle, entry.BookAuthors)
 
 		if _, exists := booksMap[key]; !exists {
 			booksMap[key] = len(books)
 			books = append(books, model.Book{
-				Title:            entry.BookTitle,
-				Filename:         entry.Filename,
-				Author:           entry.BookAuthor,
-				FirstHighlightDt: entry.Date,
-				Highlights:       make([]model.Highlight, 0),
+				ID:         key,
+				Title:      entry.BookTitle,
+				Info:       entry.BookInfo,
+				Author:     entry.BookAuthor,
+				Authors:    entry.BookAuthors,
+				Highlights: make([]model.Highlight, 0),
 			})
 		}
 
 		index := booksMap[key]
 		bk := books[index]
-		bk.LastHighlightDt = entry.Date
-		bk.Highlights = append(bk.Highlights, model.Highlight{
-			Date: entry.Date,
-			Text: entry.HighlightText,
-		})
+
+		switch entry.Type {
+		case parser.EntryBookmark:
+			bk.Bookmarks = append(bk.Bookmarks, model.Bookmark{
+				Date:     entry.Date,
+				Page:     entry.Page,
+				Location: entry.Location,
+			})
+		case parser.EntryNote:
+			// notes are attached once all highlights of the book are known
+			notesMap[index] = append(notesMap[index], entry)
+		default:
+			if !entry.Date.IsZero() {
+				if bk.FirstHighlightDt.IsZero() {
+					bk.FirstHighlightDt = entry.Date
+				}
+				bk.LastHighlightDt = entry.Date
+			}
+			bk.Highlights = mergeHighlight(bk.Highlights, model.Highlight{
+				Date:         entry.Date,
+				Text:         entry.HighlightText,
+				Page:         entry.Page,
+				Location:     entry.Location,
+				LimitReached: entry.LimitReached,
+			})
+		}
+
 		books[index] = bk
 	}
 
-	return books, nil
+	if err := clippings.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read clippings: %w", err)
+	}
+	report.Encoding = clippings.Encoding()
+
+	for _, bk := range books {
+		if cnt := bk.LimitReachedCount(); cnt > 0 {
+			report.LimitReached = append(report.LimitReached, LimitReached{
+				Title: bk.Title,
+				Count: cnt,
+			})
+		}
+	}
+
+	for index, notes := range notesMap {
+		books[index] = attachNotes(books[index], notes)
+	}
+
+	for _, bk := range books {
+		sort.SliceStable(bk.Highlights, func(i, j int) bool {
+			return bk.Highlights[i].Before(bk.Highlights[j])
+		})
+	}
+
+	for i := range books {
+		books[i].SetHighlightIDs()
+	}
+
+	setFilenames(books, filename.Options{Transliterate: opts.Transliterate})
+
+	return books, report, nil
+}
+
+// setFilenames sets the note filenames of the books. Books whose names would
+// differ only in case or Unicode normalisation, which many file systems do
+// not tell apart, get their IDs added, so the result does not depend on the
+// order of My Clippings.txt.
+func setFilenames(books model.Books, opts filename.Options) {
+	counts := make(map[string]int, len(books))
+	for i, bk := range books {
+		books[i].Filename = filename.Build(bk.Title, bk.Author, bk.ID, opts)
+		counts[strings.ToLower(books[i].Filename)]++
+	}
+
+	for i, bk := range books {
+		if counts[strings.ToLower(bk.Filename)] > 1 {
+			books[i].Filename = filename.Disambiguate(bk.Filename, bk.ID)
+		}
+	}
+}
+
+// mergeHighlight adds h to highlights unless it is a revision of a highlight
+// already there, in which case only the latest, most complete version is kept.
+func mergeHighlight(highlights []model.Highlight, h model.Highlight) []model.Highlight {
+	for i, existing := range highlights {
+		if !h.SameAs(existing) {
+			continue
+		}
+
+		if h.Supersedes(existing) {
+			highlights[i] = h
+		}
+
+		return highlights
+	}
+
+	return append(highlights, h)
+}
+
+// attachNotes sets each note on the highlight whose location (or page, for
+// PDFs) range contains it. Kindle anchors a note at the end of the selected
+// text, so the highlight ending closest to the note wins. Notes that belong
+// to no highlight are kept as highlights of their own.
+func attachNotes(bk model.Book, notes []parser.HighlightData) model.Book {
+	for _, note := range notes {
+		idx := -1
+		for i, h := range bk.Highlights {
+			if !h.Location.Contains(note.Location) &&
+				!(h.Location.IsZero() && h.Page.Contains(note.Page)) {
+				continue
+			}
+
+			if idx < 0 || closerEnd(h, bk.Highlights[idx], note) {
+				idx = i
+			}
+		}
+
+		if idx < 0 {
+			if bk.FirstHighlightDt.IsZero() {
+				bk.FirstHighlightDt = note.Date
+			}
+			bk.Highlights = append(bk.Highlights, model.Highlight{
+				Date:     note.Date,
+				Text:     note.HighlightText,
+				Page:     note.Page,
+				Location: note.Location,
+			})
+			continue
+		}
+
+		h := bk.Highlights[idx]
+		if h.Note != "" {
+			h.Note += "\n"
+		}
+		h.Note += note.HighlightText
+		bk.Highlights[idx] = h
+	}
+
+	return bk
+}
+
+// closerEnd reports whether a ends closer to the note than b, preferring
+// the most recent highlight on ties.
+func closerEnd(a, b model.Highlight, note parser.HighlightData) bool {
+	distA := a.Location.End - note.Location.Start
+	distB := b.Location.End - note.Location.Start
+	if a.Location.IsZero() {
+		distA = a.Page.End - note.Page.Start
+		distB = b.Page.End - note.Page.Start
+	}
+
+	if distA != distB {
+		return distA < distB
+	}
+
+	return a.Date.After(b.Date)
 }
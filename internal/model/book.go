@@ -1,21 +1,168 @@
 package model
 
 import (
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/pkg/hashs"
 )
 
 type Book struct {
-	Title            string
-	Filename         string
-	Author           string
+	// ID identifies the book independently of the note's filename, see
+	// BookID.
+	ID    string
+	Title string
+	// Info is the title line as written by Kindle, e.g. "Thinking, Fast and
+	// Slow (Kahneman, Daniel)".
+	Info     string
+	Filename string
+	// Author is the author as written by Kindle, e.g. "Kahneman, Daniel".
+	Author string
+	// Authors are the names of the authors, e.g. "Daniel Kahneman".
+	Authors          []string
 	FirstHighlightDt time.Time
 	LastHighlightDt  time.Time
 	Highlights       []Highlight
+	Bookmarks        []Bookmark
 }
 
 type Highlight struct {
-	Date time.Time
-	Text string
+	// ID identifies the highlight in the notes, see Book.SetHighlightIDs.
+	ID       string
+	Date     time.Time
+	Text     string
+	Page     Range
+	Location Range
+	// Note is the text of the Kindle notes attached to this highlight.
+	Note string
+	// LimitReached is set when the text was replaced or cut by Kindle
+	// because the clipping limit of the book was reached.
+	LimitReached bool
+}
+
+// SameAs reports whether h and o are revisions of one highlight, e.g. when a
+// highlight was extended on the device and Kindle wrote a second entry.
+// One text must contain the other; highlights with locations must overlap as
+// well. Locations are too coarse to tell highlights apart on their own.
+func (h Highlight) SameAs(o Highlight) bool {
+	a, b := strings.TrimSpace(h.Text), strings.TrimSpace(o.Text)
+	if a == "" || b == "" {
+		return false
+	}
+
+	if !h.Location.IsZero() && !o.Location.IsZero() {
+		return h.Location.Overlaps(o.Location) &&
+			(strings.Contains(a, b) || strings.Contains(b, a))
+	}
+
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// Supersedes reports whether h should replace o when both are revisions of
+// the same highlight: the latest one wins, then the most complete one.
+func (h Highlight) Supersedes(o Highlight) bool {
+	if !h.Date.Equal(o.Date) {
+		return h.Date.After(o.Date)
+	}
+
+	return len(h.Text) > len(o.Text)
+}
+
+type Bookmark struct {
+	Date     time.Time
+	Page     Range
+	Location Range
+}
+
+// Before reports whether h comes before o in the book. Locations are
+// compared first, then pages (PDFs have no locations) and finally dates.
+func (h Highlight) Before(o Highlight) bool {
+	switch {
+	case !h.Location.IsZero() && !o.Location.IsZero():
+		if h.Location.Start != o.Location.Start {
+			return h.Location.Start < o.Location.Start
+		}
+		return h.Location.End < o.Location.End
+	case !h.Page.IsZero() && !o.Page.IsZero():
+		if h.Page.Start != o.Page.Start {
+			return h.Page.Start < o.Page.Start
+		}
+		return h.Page.End < o.Page.End
+	default:
+		return h.Date.Before(o.Date)
+	}
+}
+
+// BookID returns a stable ID of the book derived from its title and
+// authors, ignoring case and whitespace differences.
+func BookID(title string, authors []string) string {
+	key := normalizeKey(title)
+	for _, a := range authors {
+		key += "\x00" + normalizeKey(a)
+	}
+
+	return hashs.FNV64a(key)
+}
+
+func normalizeKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// SetHighlightIDs gives every highlight a stable ID derived from the book ID
+// and the start of its location, or page for PDFs, so that the ID survives
+// extending the highlight on the device and fixing its text in the note.
+// Highlights without a position or sharing their start with another one
+// fall back to their text.
+func (b *Book) SetHighlightIDs() {
+	starts := make(map[string]int, len(b.Highlights))
+	for _, h := range b.Highlights {
+		starts[h.positionKey()]++
+	}
+
+	for i, h := range b.Highlights {
+		key := h.positionKey()
+		if key == "" || starts[key] > 1 {
+			key = "text:" + normalizeKey(h.Text)
+		}
+		b.Highlights[i].ID = hashs.FNV64a(b.ID + "\x00" + key)
+	}
+}
+
+func (h Highlight) positionKey() string {
+	switch {
+	case !h.Location.IsZero():
+		return "loc:" + strconv.Itoa(h.Location.Start)
+	case !h.Page.IsZero():
+		return "page:" + strconv.Itoa(h.Page.Start)
+	default:
+		return ""
+	}
+}
+
+// HighlightCount returns the number of highlights with text.
+func (b Book) HighlightCount() int {
+	cnt := 0
+	for _, h := range b.Highlights {
+		if h.Text != "" {
+			cnt++
+		}
+	}
+
+	return cnt
+}
+
+// LimitReachedCount returns the number of highlights cut by the clipping
+// limit.
+func (b Book) LimitReachedCount() int {
+	cnt := 0
+	for _, h := range b.Highlights {
+		if h.LimitReached {
+			cnt++
+		}
+	}
+
+	return cnt
 }
 
 type Books []Book
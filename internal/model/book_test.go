@@ -0,0 +1,53 @@
+package model
+
+import "testing"
+
+func TestHighlightSameAs(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Highlight
+		want bool
+	}{
+		{
+			name: "extended on the device",
+			a:    Highlight{Text: "Short", Location: Range{Start: 200, End: 201}},
+			b:    Highlight{Text: "Short and extended.", Location: Range{Start: 200, End: 202}},
+			want: true,
+		},
+		{
+			name: "different text at the same location",
+			a:    Highlight{Text: "First sentence of the highlight.", Location: Range{Start: 100, End: 101}},
+			b:    Highlight{Text: "A totally different second sentence.", Location: Range{Start: 101}},
+			want: false,
+		},
+		{
+			name: "same text elsewhere",
+			a:    Highlight{Text: "Text", Location: Range{Start: 100}},
+			b:    Highlight{Text: "Text", Location: Range{Start: 300}},
+			want: false,
+		},
+		{
+			name: "prefix without locations",
+			a:    Highlight{Text: "Beginning", Page: Range{Start: 3}},
+			b:    Highlight{Text: "Beginning of the text", Page: Range{Start: 3}},
+			want: true,
+		},
+		{
+			name: "contained without locations",
+			a:    Highlight{Text: "of the"},
+			b:    Highlight{Text: "Beginning of the text"},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.SameAs(tt.b); got != tt.want {
+				t.Errorf("SameAs() = %v, want %v", got, tt.want)
+			}
+			if got := tt.b.SameAs(tt.a); got != tt.want {
+				t.Errorf("reversed SameAs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config is the optional configuration file of the tool.
+type Config struct {
+	// Timezone is the IANA zone of Kindle timestamps, e.g. "Europe/Berlin".
+	// Empty means the local zone of the computer.
+	Timezone string `json:"timezone"`
+	// Similarity is the threshold from which a highlight in a note is taken
+	// as an edited version of a highlight on the device, between 0 and 1.
+	// Zero means the default.
+	Similarity float64 `json:"similarity"`
+	// Template is the name of a built-in template set or the path to a
+	// template file or directory. Empty means the default set.
+	Template string `json:"template"`
+	// Style is how highlights are rendered, e.g. "callout". Empty means the
+	// way of the template.
+	Style string `json:"style"`
+	// Transliterate writes the filenames of new notes in ASCII where
+	// possible.
+	Transliterate bool     `json:"transliterate"`
+	Devices       []Device `json:"devices"`
+}
+
+// Device holds the settings of one Kindle, selected by name or by the path
+// of its My Clippings.txt.
+type Device struct {
+	Name string `json:"name"`
+	// Input is the path to My Clippings.txt of the device.
+	Input string `json:"input"`
+	// Timezone overrides Config.Timezone for this device.
+	Timezone string `json:"timezone"`
+}
+
+func (c Config) Validate() error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	if c.Similarity < 0 || c.Similarity > 1 {
+		return fmt.Errorf("invalid similarity: %v", c.Similarity)
+	}
+
+	names := make(map[string]struct{}, len(c.Devices))
+	for _, d := range c.Devices {
+		if d.Name == "" {
+			return errors.New("required: Device.Name")
+		}
+		if _, exists := names[d.Name]; exists {
+			return fmt.Errorf("duplicate device: %s", d.Name)
+		}
+		names[d.Name] = struct{}{}
+
+		if _, err := time.LoadLocation(d.Timezone); err != nil {
+			return fmt.Errorf("device %s: invalid timezone: %w", d.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Device returns the device called name or, when name is empty, the device
+// whose Input is inputFile.
+func (c Config) Device(name, inputFile string) (Device, bool) {
+	for _, d := range c.Devices {
+		if name != "" && d.Name == name {
+			return d, true
+		}
+		if name == "" && inputFile != "" && d.Input == inputFile {
+			return d, true
+		}
+	}
+
+	return Device{}, false
+}
+
+// Location returns the time zone of the device's timestamps: the device's
+// own zone, then the configured one, then the local zone.
+func (c Config) Location(d Device) (*time.Location, error) {
+	switch {
+	case d.Timezone != "":
+		return time.LoadLocation(d.Timezone)
+	case c.Timezone != "":
+		return time.LoadLocation(c.Timezone)
+	default:
+		return time.Local, nil
+	}
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"strconv"
+)
+
+// Range is an inclusive span of pages or locations as reported by Kindle.
+// The zero value means that the position is unknown.
+type Range struct {
+	Start int
+	End   int
+}
+
+func (r Range) IsZero() bool {
+	return r.Start == 0 && r.End == 0
+}
+
+// String formats the range as "1293" or "1293-1294".
+func (r Range) String() string {
+	if r.IsZero() {
+		return ""
+	}
+
+	if r.End <= r.Start {
+		return strconv.Itoa(r.Start)
+	}
+
+	return strconv.Itoa(r.Start) + "-" + strconv.Itoa(r.End)
+}
+
+// Contains reports whether o lies entirely within r.
+func (r Range) Contains(o Range) bool {
+	if r.IsZero() || o.IsZero() {
+		return false
+	}
+
+	return r.Start <= o.Start && o.end() <= r.end()
+}
+
+// Overlaps reports whether r and o share at least one page or location.
+func (r Range) Overlaps(o Range) bool {
+	if r.IsZero() || o.IsZero() {
+		return false
+	}
+
+	return r.Start <= o.end() && o.Start <= r.end()
+}
+
+func (r Range) end() int {
+	if r.End < r.Start {
+		return r.Start
+	}
+
+	return r.End
+}
@@ -2,10 +2,41 @@ package model
 
 import (
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goodsign/monday"
 )
 
+// Translation describes the metaInfo grammar of one Kindle language, e.g.
+// "- Your Highlight on page 12 | Location 1293-1294 | Added on Sunday, December 1, 2013 7:49:48 PM".
 type Translation struct {
+	// AddedOn is the phrase in front of the date, it identifies the language.
 	AddedOn string `json:"added_on"`
+
+	// Highlight, Note and Bookmark are the words naming the entry type.
+	Highlight []string `json:"highlight"`
+	Note      []string `json:"note"`
+	Bookmark  []string `json:"bookmark"`
+
+	// Page and Location are the words in front of the numbers.
+	Page     []string `json:"page"`
+	Location []string `json:"location"`
+
+	// ClippingLimit are phrases of the message written instead of the text
+	// once the publisher's clipping limit is reached.
+	ClippingLimit []string `json:"clipping_limit"`
+
+	// DateLayouts are time.Parse layouts, with weekday and month names in
+	// English, tried in order for the date following AddedOn.
+	DateLayouts []string `json:"date_layouts"`
+	// DateReplacements are applied to the date before parsing, e.g. to drop
+	// "水曜日" or turn "午後" into "PM" for layouts with numeric months.
+	DateReplacements map[string]string `json:"date_replacements"`
+	// Locale is the monday locale used to translate weekday and month names,
+	// e.g. "ru_RU". Languages writing dates with numbers only leave it empty.
+	Locale string `json:"locale"`
 }
 
 func (t Translation) Validate() error {
@@ -13,5 +44,60 @@ func (t Translation) Validate() error {
 		return errors.New("required: AddedOn")
 	}
 
+	words := map[string][]string{
+		"Highlight": t.Highlight,
+		"Note":      t.Note,
+		"Bookmark":  t.Bookmark,
+		"Page":      t.Page,
+		"Location":  t.Location,
+	}
+	for _, name := range []string{"Highlight", "Note", "Bookmark", "Page", "Location"} {
+		if len(words[name]) == 0 {
+			return fmt.Errorf("required: %s", name)
+		}
+		for _, w := range words[name] {
+			if strings.TrimSpace(w) == "" {
+				return fmt.Errorf("empty word in %s", name)
+			}
+		}
+	}
+
+	for _, phrase := range t.ClippingLimit {
+		if strings.TrimSpace(phrase) == "" {
+			return errors.New("empty phrase in ClippingLimit")
+		}
+	}
+
+	if len(t.DateLayouts) == 0 {
+		return errors.New("required: DateLayouts")
+	}
+	for _, layout := range t.DateLayouts {
+		// a layout must at least round-trip a date
+		ref := time.Date(2013, time.December, 1, 19, 49, 48, 0, time.UTC)
+		if _, err := time.Parse(layout, ref.Format(layout)); err != nil {
+			return fmt.Errorf("invalid date layout %q: %w", layout, err)
+		}
+	}
+
+	for word := range t.DateReplacements {
+		if word == "" {
+			return errors.New("empty word in DateReplacements")
+		}
+	}
+
+	if t.Locale != "" && !isKnownLocale(t.Locale) {
+		return fmt.Errorf("unknown locale: %s", t.Locale)
+	}
+
 	return nil
 }
+
+func isKnownLocale(locale string) bool {
+	for _, l := range monday.ListLocales() {
+		if string(l) == locale {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,137 @@
+package output
+
+import (
+	"strings"
+)
+
+const (
+	frontmatterDelimiter = "---"
+
+	// bookIDProperty identifies the book of a note, see model.BookID.
+	bookIDProperty = "kindle_book_id"
+)
+
+// frontmatter is the YAML frontmatter of a note as an ordered list of
+// top-level properties. Each property keeps its lines as written, so that
+// properties the tool does not touch are written back unchanged.
+type frontmatter struct {
+	props []property
+}
+
+// property is a top-level "key: value" line together with the indented or
+// list lines that belong to it.
+type property struct {
+	key   string
+	lines []string
+}
+
+// splitFrontmatter returns the frontmatter lines without the delimiters and
+// the index of the first line after the closing delimiter. ok is false when
+// the note has no frontmatter.
+func splitFrontmatter(lines []string) (fm []string, bodyStart int, ok bool) {
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \r") != frontmatterDelimiter {
+		return nil, 0, false
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], " \r") == frontmatterDelimiter {
+			return lines[1:i], i + 1, true
+		}
+	}
+
+	return nil, 0, false
+}
+
+func parseFrontmatter(lines []string) *frontmatter {
+	fm := &frontmatter{}
+	for _, line := range lines {
+		key, isKey := propertyKey(line)
+		if isKey || len(fm.props) == 0 {
+			fm.props = append(fm.props, property{key: key})
+		}
+
+		last := &fm.props[len(fm.props)-1]
+		last.lines = append(last.lines, line)
+	}
+
+	return fm
+}
+
+// propertyKey returns the key of a top-level "key: value" line.
+func propertyKey(line string) (string, bool) {
+	if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '-' || line[0] == '#' {
+		return "", false
+	}
+
+	i := strings.Index(line, ":")
+	if i <= 0 {
+		return "", false
+	}
+
+	return strings.Trim(strings.TrimSpace(line[:i]), `"'`), true
+}
+
+func (fm *frontmatter) index(key string) int {
+	for i, p := range fm.props {
+		if p.key != "" && p.key == key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// value returns the value of a single line property without quotes.
+func (fm *frontmatter) value(key string) string {
+	i := fm.index(key)
+	if i < 0 {
+		return ""
+	}
+
+	line := fm.props[i].lines[0]
+	value := strings.TrimSpace(line[strings.Index(line, ":")+1:])
+
+	return strings.Trim(value, `"'`)
+}
+
+// set replaces the property with the same key or adds it at the end.
+func (fm *frontmatter) set(p property) {
+	if i := fm.index(p.key); i >= 0 {
+		fm.props[i] = p
+		return
+	}
+
+	fm.props = append(fm.props, p)
+}
+
+// managedProperties are derived from the book and taken over from the
+// template on every sync. All other properties belong to the user once the
+// note exists, like "tags" or "my_rating".
+var managedProperties = map[string]struct{}{
+	"authors":                {},
+	"date":                   {},
+	"clipping_limit_reached": {},
+	"highlight_count":        {},
+	"last_synced":            {},
+	bookIDProperty:           {},
+}
+
+// mergeRendered takes over the managed properties from rendered, keeping all
+// others as they are. The other properties of the template are only written
+// with the note, so that the ones the user deleted do not come back.
+func (fm *frontmatter) mergeRendered(rendered *frontmatter) {
+	for _, p := range rendered.props {
+		if _, managed := managedProperties[p.key]; managed {
+			fm.set(p)
+		}
+	}
+}
+
+func (fm *frontmatter) lines() []string {
+	var lines []string
+	for _, p := range fm.props {
+		lines = append(lines, p.lines...)
+	}
+
+	return lines
+}
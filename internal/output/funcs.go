@@ -0,0 +1,225 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/goodsign/monday"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+// funcMap is available to all templates.
+var funcMap = template.FuncMap{
+	"inZone":     inZone,
+	"date":       date,
+	"localDate":  localDate,
+	"slugify":    slugify,
+	"wikilink":   wikilink,
+	"blockquote": blockquote,
+	"indent":     indent,
+	"truncate":   truncate,
+	"groupBy":    groupBy,
+	"sortBy":     sortBy,
+	"pluralize":  pluralize,
+	"yaml":       yamlString,
+	"markdown":   escapeMarkdown,
+	"indentRest": indentRest,
+	"callout":    callout,
+	"tableCell":  tableCell,
+}
+
+// inZone converts t to the IANA zone name, so that templates can render
+// timestamps in another zone than the device's:
+//
+//	{{ .Date | inZone "America/New_York" }}
+func inZone(name string, t time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("load location: %w", err)
+	}
+
+	return t.In(loc), nil
+}
+
+// date formats t with a Go layout, the zero time as an empty string:
+//
+//	{{ .Date | date "2 January 2006" }}
+func date(layout string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(layout)
+}
+
+// localDate is date with weekday and month names in a monday locale:
+//
+//	{{ .Date | localDate "de_DE" "2. January 2006" }}
+func localDate(locale, layout string, t time.Time) (string, error) {
+	if t.IsZero() {
+		return "", nil
+	}
+
+	for _, l := range monday.ListLocales() {
+		if string(l) == locale {
+			return monday.Format(t, layout, l), nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown locale: %s", locale)
+}
+
+// slugify turns s into lower case words joined by dashes, keeping letters
+// of all scripts:
+//
+//	{{ .Title | slugify }} → thinking-fast-and-slow
+func slugify(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	return strings.Join(words, "-")
+}
+
+// wikilinkReplacer drops the characters Obsidian does not allow in links.
+var wikilinkReplacer = strings.NewReplacer(
+	"[", "", "]", "", "|", "", "#", "", "^", "", "\n", " ",
+)
+
+// wikilink links to the note called s:
+//
+//	{{ range .Authors }}{{ wikilink . }}{{ end }} → [[Daniel Kahneman]]
+func wikilink(s string) string {
+	return "[[" + strings.TrimSpace(wikilinkReplacer.Replace(s)) + "]]"
+}
+
+// blockquote quotes every line of s:
+//
+//	{{ .Text | blockquote }}
+func blockquote(s string) string {
+	return prefixLines("> ", s)
+}
+
+// indent indents every line of s by n spaces, e.g. to put multi-line text
+// below a list item:
+//
+//	{{ .Note | indent 4 }}
+func indent(n int, s string) string {
+	return prefixLines(strings.Repeat(" ", n), s)
+}
+
+func prefixLines(prefix, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(prefix+l, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// truncate cuts s to at most n characters, ending in "…" when cut:
+//
+//	{{ .Text | truncate 80 }}
+func truncate(n int, s string) string {
+	runes := []rune(s)
+	if n <= 0 || len(runes) <= n {
+		return s
+	}
+
+	return strings.TrimRightFunc(string(runes[:n-1]), unicode.IsSpace) + "…"
+}
+
+// highlightGroup is what groupBy returns: the key of the group and its
+// highlights in their order.
+type highlightGroup struct {
+	Key        string
+	Highlights []model.Highlight
+}
+
+// groupBy groups highlights by "year" or "month" of their date, or by
+// "page". Groups are in the order of their first highlight. My Clippings.txt
+// has no chapters, so highlights cannot be grouped by them:
+//
+//	{{ range groupBy "month" .Highlights }}### {{ .Key }}{{ range .Highlights }}...{{ end }}{{ end }}
+func groupBy(key string, highlights []model.Highlight) ([]highlightGroup, error) {
+	var keyOf func(h model.Highlight) string
+	switch key {
+	case "year":
+		keyOf = func(h model.Highlight) string { return date("2006", h.Date) }
+	case "month":
+		keyOf = func(h model.Highlight) string { return date("2006-01", h.Date) }
+	case "page":
+		keyOf = func(h model.Highlight) string {
+			if h.Page.IsZero() {
+				return ""
+			}
+			return strconv.Itoa(h.Page.Start)
+		}
+	default:
+		return nil, fmt.Errorf("unknown group key: %s", key)
+	}
+
+	var groups []highlightGroup
+	index := make(map[string]int)
+	for _, h := range highlights {
+		k := keyOf(h)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, highlightGroup{Key: k})
+		}
+		groups[i].Highlights = append(groups[i].Highlights, h)
+	}
+
+	return groups, nil
+}
+
+// sortBy returns the highlights sorted by "location", their order in the
+// book, or by "date", oldest first:
+//
+//	{{ range sortBy "date" .Highlights }}...{{ end }}
+func sortBy(key string, highlights []model.Highlight) ([]model.Highlight, error) {
+	var less func(a, b model.Highlight) bool
+	switch key {
+	case "location":
+		less = model.Highlight.Before
+	case "date":
+		less = func(a, b model.Highlight) bool { return a.Date.Before(b.Date) }
+	default:
+		return nil, fmt.Errorf("unknown sort key: %s", key)
+	}
+
+	sorted := append([]model.Highlight(nil), highlights...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+
+	return sorted, nil
+}
+
+// pluralize returns n followed by the singular or plural word:
+//
+//	{{ pluralize .HighlightCount "highlight" "highlights" }} → 3 highlights
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+
+	return strconv.Itoa(n) + " " + plural
+}
+
+// yamlString quotes s as a YAML double-quoted scalar, safe for titles with
+// colons, quotes or leading dashes:
+//
+//	title: {{ .Title | yaml }}
+func yamlString(s string) string {
+	// the escapes of Go strings are a subset of the YAML ones
+	return strconv.Quote(s)
+}
@@ -0,0 +1,287 @@
+package output
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+var testDate = time.Date(2013, 12, 1, 19, 49, 48, 0, time.UTC)
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		layout string
+		t      time.Time
+		want   string
+	}{
+		{"2 January 2006", testDate, "1 December 2013"},
+		{"2006-01-02 15:04", testDate, "2013-12-01 19:49"},
+		{"2 January 2006", time.Time{}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := date(tt.layout, tt.t); got != tt.want {
+			t.Errorf("date(%q, %v) = %q, want %q", tt.layout, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestLocalDate(t *testing.T) {
+	tests := []struct {
+		locale, layout string
+		t              time.Time
+		want           string
+		wantErr        bool
+	}{
+		{"de_DE", "2. January 2006", testDate, "1. Dezember 2013", false},
+		{"ru_RU", "Monday, 2 January 2006", testDate, "Воскресенье, 1 декабря 2013", false},
+		{"en_US", "January 2006", testDate, "December 2013", false},
+		{"de_DE", "2. January 2006", time.Time{}, "", false},
+		{"xx_XX", "2006", testDate, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := localDate(tt.locale, tt.layout, tt.t)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("localDate(%q, %q) error = %v, want error %v", tt.locale, tt.layout, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("localDate(%q, %q) = %q, want %q", tt.locale, tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestInZone(t *testing.T) {
+	tests := []struct {
+		zone    string
+		want    string
+		wantErr bool
+	}{
+		{"America/New_York", "2013-12-01 14:49 EST", false},
+		{"Asia/Tokyo", "2013-12-02 04:49 JST", false},
+		{"UTC", "2013-12-01 19:49 UTC", false},
+		{"Nowhere/City", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := inZone(tt.zone, testDate)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("inZone(%q) error = %v, want error %v", tt.zone, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Format("2006-01-02 15:04 MST") != tt.want {
+			t.Errorf("inZone(%q) = %v, want %s", tt.zone, got, tt.want)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"Thinking, Fast and Slow", "thinking-fast-and-slow"},
+		{"  Don't Panic!  ", "don-t-panic"},
+		{"Война и мир", "война-и-мир"},
+		{"1984", "1984"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.s); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestWikilink(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"Daniel Kahneman", "[[Daniel Kahneman]]"},
+		{"A [[nested]] | #tag ^id", "[[A nested  tag id]]"},
+		{"Two\nlines", "[[Two lines]]"},
+		{" padded ", "[[padded]]"},
+	}
+
+	for _, tt := range tests {
+		if got := wikilink(tt.s); got != tt.want {
+			t.Errorf("wikilink(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestBlockquote(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"text", "> text"},
+		{"one\ntwo", "> one\n> two"},
+		{"one\n\ntwo", "> one\n>\n> two"},
+	}
+
+	for _, tt := range tests {
+		if got := blockquote(tt.s); got != tt.want {
+			t.Errorf("blockquote(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	tests := []struct {
+		n       int
+		s, want string
+	}{
+		{4, "text", "    text"},
+		{2, "one\ntwo", "  one\n  two"},
+		{2, "one\n\ntwo", "  one\n\n  two"},
+		{0, "text", "text"},
+	}
+
+	for _, tt := range tests {
+		if got := indent(tt.n, tt.s); got != tt.want {
+			t.Errorf("indent(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n       int
+		s, want string
+	}{
+		{10, "short", "short"},
+		{5, "exact", "exact"},
+		{8, "a longer text", "a longe…"},
+		{3, "a longer text", "a…"},
+		{4, "привет", "при…"},
+		{0, "text", "text"},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.n, tt.s); got != tt.want {
+			t.Errorf("truncate(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	highlights := []model.Highlight{
+		{Text: "a", Date: time.Date(2013, 12, 1, 0, 0, 0, 0, time.UTC), Page: model.Range{Start: 1}},
+		{Text: "b", Date: time.Date(2014, 1, 5, 0, 0, 0, 0, time.UTC), Page: model.Range{Start: 2}},
+		{Text: "c", Date: time.Date(2013, 12, 9, 0, 0, 0, 0, time.UTC), Page: model.Range{Start: 1, End: 2}},
+		{Text: "d"},
+	}
+
+	tests := []struct {
+		key     string
+		want    map[string]string
+		order   []string
+		wantErr bool
+	}{
+		{"year", map[string]string{"2013": "ac", "2014": "b", "": "d"}, []string{"2013", "2014", ""}, false},
+		{"month", map[string]string{"2013-12": "ac", "2014-01": "b", "": "d"}, []string{"2013-12", "2014-01", ""}, false},
+		{"page", map[string]string{"1": "ac", "2": "b", "": "d"}, []string{"1", "2", ""}, false},
+		{"chapter", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		groups, err := groupBy(tt.key, highlights)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("groupBy(%q) error = %v, want error %v", tt.key, err, tt.wantErr)
+			continue
+		}
+
+		var order []string
+		for _, g := range groups {
+			order = append(order, g.Key)
+
+			var texts string
+			for _, h := range g.Highlights {
+				texts += h.Text
+			}
+			if texts != tt.want[g.Key] {
+				t.Errorf("groupBy(%q) group %q = %q, want %q", tt.key, g.Key, texts, tt.want[g.Key])
+			}
+		}
+		if !reflect.DeepEqual(order, tt.order) {
+			t.Errorf("groupBy(%q) keys = %q, want %q", tt.key, order, tt.order)
+		}
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	highlights := []model.Highlight{
+		{Text: "b", Date: time.Date(2013, 12, 1, 0, 0, 0, 0, time.UTC), Location: model.Range{Start: 200}},
+		{Text: "c", Date: time.Date(2013, 11, 1, 0, 0, 0, 0, time.UTC), Location: model.Range{Start: 300}},
+		{Text: "a", Date: time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC), Location: model.Range{Start: 100}},
+	}
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"location", "abc", false},
+		{"date", "cba", false},
+		{"title", "", true},
+	}
+
+	for _, tt := range tests {
+		sorted, err := sortBy(tt.key, highlights)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("sortBy(%q) error = %v, want error %v", tt.key, err, tt.wantErr)
+			continue
+		}
+
+		var got string
+		for _, h := range sorted {
+			got += h.Text
+		}
+		if got != tt.want {
+			t.Errorf("sortBy(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+
+	if highlights[0].Text != "b" {
+		t.Errorf("sortBy changed the order of its argument")
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0 highlights"},
+		{1, "1 highlight"},
+		{2, "2 highlights"},
+	}
+
+	for _, tt := range tests {
+		if got := pluralize(tt.n, "highlight", "highlights"); got != tt.want {
+			t.Errorf("pluralize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestYAMLString(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"Thinking, Fast and Slow", `"Thinking, Fast and Slow"`},
+		{"Title: Subtitle", `"Title: Subtitle"`},
+		{`A "quoted" word`, `"A \"quoted\" word"`},
+		{"- leading dash", `"- leading dash"`},
+		{"Война и мир", `"Война и мир"`},
+		{"two\nlines", `"two\nlines"`},
+	}
+
+	for _, tt := range tests {
+		if got := yamlString(tt.s); got != tt.want {
+			t.Errorf("yamlString(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package output
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// inlineEscaped are escaped wherever they appear: they start code, emphasis,
+// links, math, tags, block IDs, HTML or table cells in Obsidian Markdown.
+const inlineEscaped = "\\`*_[]|$#^<"
+
+// pairEscaped are escaped where doubled, as in "==mark==", "~~strike~~" and
+// "%%comment%%", or in code fences like "~~~".
+const pairEscaped = "=~%"
+
+// orderedListRe matches the start of an ordered list item, "1." or "1)".
+var orderedListRe = regexp.MustCompile(`^(\d{1,9})([.)])(\s|$)`)
+
+// calloutRe matches the first line of a callout, "> [!quote] Title".
+var calloutRe = regexp.MustCompile(`^>\s?\[![\w-]+\][+-]?`)
+
+// escapeMarkdown escapes text, which may span several lines, so that it is
+// rendered as written inside a list item, blockquote or callout. Only ASCII
+// punctuation is escaped, unescapeMarkdown turns it back into text.
+func escapeMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = escapeLine(strings.TrimSpace(line))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func escapeLine(line string) string {
+	// the "." or ")" of "1." or "1)"
+	orderedEnd := -1
+	if m := orderedListRe.FindStringSubmatchIndex(line); m != nil {
+		orderedEnd = m[4]
+	}
+
+	var sb strings.Builder
+	prev := rune(-1)
+	for i, r := range line {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		next, _ := utf8.DecodeRuneInString(line[i+size:])
+		doubled := prev == r || next == r
+		if strings.ContainsRune(inlineEscaped, r) ||
+			strings.ContainsRune(pairEscaped, r) && doubled ||
+			i == 0 && blockStart(line) ||
+			i == orderedEnd {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+		prev = r
+	}
+
+	return sb.String()
+}
+
+// blockStart reports whether line would start a block other than a
+// paragraph: a list item, blockquote, heading underline or thematic break.
+// "___" is escaped as emphasis already.
+func blockStart(line string) bool {
+	if line == "" {
+		return false
+	}
+
+	switch line[0] {
+	case '>', '=':
+		return true
+	case '-', '+':
+		return len(line) == 1 || line[1] == ' ' || strings.Trim(line, "- ") == ""
+	}
+
+	return false
+}
+
+// unescapeMarkdown drops the backslashes in front of ASCII punctuation.
+func unescapeMarkdown(text string) string {
+	if !strings.Contains(text, "\\") {
+		return text
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && isASCIIPunct(text[i+1]) {
+			i++
+		}
+		sb.WriteByte(text[i])
+	}
+
+	return sb.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// indentRest indents the lines of s after the first one by n spaces, so that
+// text spanning several lines stays in its list item:
+//
+//   - {{ .Text | markdown | indentRest 2 }}
+func indentRest(n int, s string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = strings.Repeat(" ", n) + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// callout puts s into an Obsidian callout of the kind, e.g. "quote":
+//
+//	{{ .Text | markdown | callout "quote" }}
+func callout(kind, s string) string {
+	return "> [!" + kind + "]\n" + blockquote(s)
+}
+
+// tableCell puts s into a table cell, with "<br>" for line breaks. Pipes
+// are escaped by escapeMarkdown already:
+//
+//	| {{ .Text | markdown | tableCell }} |
+func tableCell(s string) string {
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// delimiterRowRe matches the row below the header of a table, "| --- |".
+var delimiterRowRe = regexp.MustCompile(`^\|?(\s*:?-+:?\s*\|)+\s*(:?-+:?\s*)?$`)
+
+// splitCells returns the cells of a table row, splitting on the pipes that
+// are not escaped.
+func splitCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, "\\|") {
+		row = row[:len(row)-1]
+	}
+
+	var cells []string
+	start := 0
+	for i := 0; i < len(row); i++ {
+		switch row[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, strings.TrimSpace(row[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(cells, strings.TrimSpace(row[start:]))
+}
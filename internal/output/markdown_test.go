@@ -0,0 +1,109 @@
+package output
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+// markdownTexts are highlight texts full of Markdown syntax.
+var markdownTexts = []string{
+	"# Not a heading",
+	"- not a list",
+	"+ not a list either",
+	"1. not ordered",
+	"2) not ordered either",
+	"> not a quote",
+	"a [[link]] and a | pipe and *stars* and `code`",
+	"a [link](http://example.com) and ![image](x.png)",
+	"a_b_c __init__ and _emphasis_",
+	"Line one\nLine two\n\nAfter blank line",
+	"costs $5 and $10 #tag ^ref <b>bold</b>",
+	"===",
+	"---",
+	"___",
+	"~~~ fence",
+	"==mark== ~~strike~~ %%comment%% 100%",
+	`back\slash and \* escaped`,
+	"ends with (loc. 12)",
+	"ends with ^abc",
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"a_b_c __init__", `a\_b\_c \_\_init\_\_`},
+		{"[link](http://x)", `\[link\](http://x)`},
+		{"# heading", `\# heading`},
+		{"1. one", `1\. one`},
+		{"- dash", `\- dash`},
+		{"a - b", "a - b"},
+		{"==mark== a=b", `\=\=mark\=\= a=b`},
+		{"plain text.", "plain text."},
+	}
+
+	for _, tt := range tests {
+		got := escapeMarkdown(tt.text)
+		if got != tt.want {
+			t.Errorf("escapeMarkdown(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if back := unescapeMarkdown(got); back != tt.text {
+			t.Errorf("unescapeMarkdown(%q) = %q, want %q", got, back, tt.text)
+		}
+	}
+}
+
+// TestEscapeMarkdownRoundTrip renders highlights in every style and reads
+// them back as the reader of existing notes does.
+func TestEscapeMarkdownRoundTrip(t *testing.T) {
+	date := time.Date(2013, 12, 1, 19, 49, 48, 0, time.UTC)
+	book := model.Book{
+		ID:      model.BookID("Nasty", []string{"Writer"}),
+		Title:   "Nasty",
+		Author:  "Writer",
+		Authors: []string{"Writer"},
+	}
+	for i, text := range markdownTexts {
+		book.Highlights = append(book.Highlights, model.Highlight{
+			Date:     date,
+			Text:     text,
+			Location: model.Range{Start: 100 + 10*i, End: 101 + 10*i},
+		})
+	}
+	book.SetHighlightIDs()
+
+	for _, style := range Styles {
+		t.Run(style, func(t *testing.T) {
+			tmpl, err := LoadTemplate(DefaultTemplate, style)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := renderBook(tmpl, book, date)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			doc := parseNote(content)
+			start, end, ok := doc.highlightsSection()
+			if !ok {
+				t.Fatal("no highlights section")
+			}
+
+			items := doc.items(start, end)
+			if len(items) != len(book.Highlights) {
+				t.Fatalf("read %d items, want %d:\n%s", len(items), len(book.Highlights), content)
+			}
+			for i, item := range items {
+				want := book.Highlights[i]
+				got := item.highlight
+				if got.Text != want.Text || got.ID != want.ID || got.Location != want.Location {
+					t.Errorf("item %d = %q %s %v, want %q %s %v",
+						i, got.Text, got.ID, got.Location, want.Text, want.ID, want.Location)
+				}
+			}
+		})
+	}
+}
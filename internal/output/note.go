@@ -0,0 +1,550 @@
+package output
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+const (
+	highlightsHeading = "## Highlights"
+	noHighlightsLine  = "No highlights available."
+
+	// regionStart and regionEnd enclose the highlights managed by the tool.
+	// They must match the markers in the templates.
+	regionStart = "<!-- kindle-highlights:start -->"
+	regionEnd   = "<!-- kindle-highlights:end -->"
+)
+
+// note is the Markdown of an exported note kept line by line, so that the
+// lines the tool does not touch are written back byte for byte.
+type note struct {
+	lines []string
+}
+
+// noteItem is a highlight in the note, a list item or a blockquote. Its text
+// is in lines[start:textEnd], the indented sub-items of a list item follow in
+// lines[textEnd:end].
+type noteItem struct {
+	start     int
+	textEnd   int
+	end       int
+	highlight model.Highlight
+}
+
+func parseNote(content string) *note {
+	return &note{lines: strings.Split(content, "\n")}
+}
+
+func (n *note) String() string {
+	return strings.Join(n.lines, "\n")
+}
+
+// highlightsSection returns the lines [start, end) of the highlights: the
+// lines between the region markers or, in notes written before the markers
+// existed, the lines below the highlights heading up to the next heading of
+// the same or a higher level.
+func (n *note) highlightsSection() (start, end int, ok bool) {
+	if start, end, ok = n.region(); ok {
+		return start, end, true
+	}
+
+	start = -1
+	inFence := false
+	for i, line := range n.lines {
+		if isFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		if start < 0 {
+			if strings.TrimRight(line, " \r") == highlightsHeading {
+				start = i + 1
+			}
+			continue
+		}
+
+		if level := headingLevel(line); level > 0 && level <= 2 {
+			return start, i, true
+		}
+	}
+
+	if start < 0 {
+		return 0, 0, false
+	}
+
+	return start, len(n.lines), true
+}
+
+// region returns the lines [start, end) between the region markers.
+func (n *note) region() (start, end int, ok bool) {
+	start = -1
+	for i, line := range n.lines {
+		switch strings.TrimSpace(line) {
+		case regionStart:
+			start = i + 1
+		case regionEnd:
+			if start >= 0 {
+				return start, i, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// errProseBetweenItems is returned by replaceRegion for notes without the
+// markers whose highlights have text of the user between them, which the
+// region would take in.
+var errProseBetweenItems = errors.New("text between the highlights of a note without markers")
+
+// replaceRegion puts the managed region of rendered in place of the
+// highlights of n. Notes without the markers get the whole region, markers
+// included, in place of the list in their highlights section or at the end.
+// Only a list without other lines between its items is replaced, see
+// errProseBetweenItems.
+func (n *note) replaceRegion(rendered *note) error {
+	start, end, ok := rendered.region()
+	if !ok {
+		return nil
+	}
+
+	if from, to, ok := n.region(); ok {
+		n.replaceLines(from, to, rendered.lines[start:end])
+		return nil
+	}
+
+	withMarkers := rendered.lines[start-1 : end+1]
+	if from, to, ok := n.highlightsSection(); ok {
+		items := n.items(from, to)
+		if len(items) == 0 {
+			n.insertAt(n.contentEnd(from, to), withMarkers)
+			return nil
+		}
+
+		for i := 1; i < len(items); i++ {
+			for _, line := range n.lines[items[i-1].end:items[i].start] {
+				if strings.TrimSpace(line) != "" {
+					return errProseBetweenItems
+				}
+			}
+		}
+
+		n.replaceLines(items[0].start, items[len(items)-1].end, withMarkers)
+		return nil
+	}
+
+	n.insertAt(n.contentEnd(0, len(n.lines)), append([]string{""}, withMarkers...))
+
+	return nil
+}
+
+// replaceFrontmatter merges the frontmatter of rendered into the one of n,
+// see frontmatter.mergeRendered.
+func (n *note) replaceFrontmatter(rendered *note) {
+	renderedLines, _, ok := splitFrontmatter(rendered.lines)
+	if !ok {
+		return
+	}
+
+	fmLines, bodyStart, ok := splitFrontmatter(n.lines)
+	if !ok {
+		n.insertAt(0, append(append([]string{frontmatterDelimiter}, renderedLines...), frontmatterDelimiter))
+		return
+	}
+
+	fm := parseFrontmatter(fmLines)
+	fm.mergeRendered(parseFrontmatter(renderedLines))
+	n.replaceLines(1, bodyStart-1, fm.lines())
+}
+
+func (n *note) replaceLines(from, to int, lines []string) {
+	res := make([]string, 0, len(n.lines)-(to-from)+len(lines))
+	res = append(res, n.lines[:from]...)
+	res = append(res, lines...)
+	res = append(res, n.lines[to:]...)
+	n.lines = res
+}
+
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level == len(line) || line[level] != ' ' {
+		return 0
+	}
+
+	return level
+}
+
+// items returns the highlights in lines [start, end), skipping code blocks.
+func (n *note) items(start, end int) []noteItem {
+	var items []noteItem
+	inFence := false
+	for i := start; i < end; i++ {
+		line := n.lines[i]
+		if isFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		var item noteItem
+		switch {
+		case strings.HasPrefix(line, "- "):
+			item = n.listItem(i, end)
+		case strings.HasPrefix(line, ">"):
+			item = n.quoteItem(i, end)
+		case strings.HasPrefix(line, "|"):
+			if delimiterRowRe.MatchString(line) ||
+				i+1 < end && delimiterRowRe.MatchString(n.lines[i+1]) {
+				// the header of the table
+				continue
+			}
+			item = n.tableRow(i)
+		default:
+			continue
+		}
+		items = append(items, item)
+		i = item.end - 1
+	}
+
+	return items
+}
+
+// listItem parses the list item starting at lines[start]. Text spanning
+// several lines continues on the following lines, indented or not, and
+// after blank lines on indented lines, as in Markdown.
+func (n *note) listItem(start, end int) noteItem {
+	item := noteItem{start: start, textEnd: start + 1}
+	text := []string{n.lines[start][2:]}
+	for item.textEnd < end {
+		line := n.lines[item.textEnd]
+		if continuesText(line) {
+			text = append(text, strings.TrimSpace(line))
+			item.textEnd++
+			continue
+		}
+
+		// a paragraph of the item after blank lines
+		next := item.textEnd
+		for next < end && strings.TrimSpace(n.lines[next]) == "" {
+			next++
+		}
+		if next == item.textEnd || next == end || !isContinuation(n.lines[next]) ||
+			!continuesText(n.lines[next]) {
+			break
+		}
+		for ; item.textEnd < next; item.textEnd++ {
+			text = append(text, "")
+		}
+	}
+
+	item.end = item.textEnd
+	for item.end < end && isContinuation(n.lines[item.end]) {
+		item.end++
+	}
+	item.highlight = parseHighlightText(strings.Join(text, "\n"))
+
+	return item
+}
+
+// quoteItem parses the blockquote or callout starting at lines[start]. A
+// list at the end of the quote holds what list items have as sub-items.
+func (n *note) quoteItem(start, end int) noteItem {
+	item := noteItem{start: start, textEnd: start}
+	if calloutRe.MatchString(n.lines[start]) {
+		item.textEnd++
+	}
+
+	var text []string
+	for item.textEnd < end && strings.HasPrefix(n.lines[item.textEnd], ">") {
+		line := strings.TrimPrefix(strings.TrimPrefix(n.lines[item.textEnd], ">"), " ")
+		if strings.HasPrefix(line, "- ") {
+			break
+		}
+		text = append(text, line)
+		item.textEnd++
+	}
+
+	item.end = item.textEnd
+	for item.end < end && strings.HasPrefix(n.lines[item.end], ">") {
+		item.end++
+	}
+	item.highlight = parseHighlightText(strings.Join(text, "\n"))
+
+	return item
+}
+
+// tableRow parses the table row lines[i]: the text, with "<br>" for line
+// breaks and the block ID at its end, followed by the position.
+func (n *note) tableRow(i int) noteItem {
+	cells := splitCells(n.lines[i])
+
+	text := strings.ReplaceAll(cells[0], "<br>", "\n")
+	var suffix string
+	if rest, _, ok := cutBlockID(text); ok {
+		text, suffix = rest, text[len(rest):]
+	}
+	if len(cells) > 1 && cells[1] != "" {
+		suffix = " (" + cells[1] + ")" + suffix
+	}
+
+	return noteItem{
+		start:     i,
+		textEnd:   i + 1,
+		end:       i + 1,
+		highlight: parseHighlightText(text + suffix),
+	}
+}
+
+// continuesText reports whether line continues the text of a list item
+// rather than starting a sub-item or another block.
+func continuesText(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "", trimmed == "-", strings.HasPrefix(trimmed, "- "):
+		return false
+	case strings.HasPrefix(line, ">"), strings.HasPrefix(trimmed, "<!--"):
+		return false
+	case headingLevel(line) > 0, isFence(line):
+		return false
+	}
+
+	return true
+}
+
+func isContinuation(line string) bool {
+	return strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+}
+
+func isFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// insertHighlight adds the lines of h to the highlights section, before the
+// first item that comes after h in the book. Notes without the section get
+// the lines at the end. A table row gets the rows of tableHeader in front
+// unless it goes into a table.
+func (n *note) insertHighlight(h model.Highlight, lines, tableHeader []string) {
+	start, end, ok := n.highlightsSection()
+	if !ok {
+		n.insertAt(n.contentEnd(0, len(n.lines)), lines)
+		return
+	}
+
+	for i := start; i < end; i++ {
+		if strings.TrimSpace(n.lines[i]) == noHighlightsLine {
+			n.lines = append(n.lines[:i], n.lines[i+1:]...)
+			end--
+			break
+		}
+	}
+
+	pos := -1
+	items := n.items(start, end)
+	for _, item := range items {
+		if h.Before(item.highlight) {
+			pos = item.start
+			break
+		}
+	}
+
+	if pos < 0 && len(items) > 0 {
+		pos = items[len(items)-1].end
+	}
+	if pos < 0 {
+		pos = n.contentEnd(start, end)
+	}
+
+	isRow := strings.HasPrefix(lines[0], "|")
+	afterRow := pos > 0 && strings.HasPrefix(n.lines[pos-1], "|")
+	beforeRow := pos < len(n.lines) && strings.HasPrefix(n.lines[pos], "|")
+	if isRow && !afterRow {
+		lines = append(append([]string{}, tableHeader...), lines...)
+	}
+
+	// quotes next to each other would merge into one, and a table or quote
+	// right after a list item would become part of its text
+	for _, item := range items {
+		if item.end == pos && !sameBlock(n.lines[item.start], lines[0]) {
+			lines = append([]string{""}, lines...)
+		}
+		if item.start == pos && !sameBlock(lines[0], n.lines[item.start]) {
+			lines = append(lines, "")
+		}
+	}
+	if !isRow && afterRow && beforeRow {
+		// the rest of the table it splits needs the header again
+		lines = append(lines, tableHeader...)
+	}
+
+	n.insertAt(pos, lines)
+}
+
+// sameBlock reports whether the items starting with the lines a and b can
+// follow each other without a blank line: list items of one list or rows of
+// one table.
+func sameBlock(a, b string) bool {
+	switch {
+	case strings.HasPrefix(a, "- "):
+		return strings.HasPrefix(b, "- ")
+	case strings.HasPrefix(a, "|"):
+		return strings.HasPrefix(b, "|")
+	}
+
+	return false
+}
+
+// tableHeader returns the header and delimiter rows of the first table in
+// the highlights section.
+func (n *note) tableHeader() ([]string, bool) {
+	start, end, ok := n.highlightsSection()
+	if !ok {
+		return nil, false
+	}
+
+	for i := start; i+1 < end; i++ {
+		if strings.HasPrefix(n.lines[i], "|") && delimiterRowRe.MatchString(n.lines[i+1]) {
+			return n.lines[i : i+2], true
+		}
+	}
+
+	return nil, false
+}
+
+// contentEnd returns the index after the last non-blank line in
+// [from, to), so that new lines go above the blank lines separating the
+// section from the next one.
+func (n *note) contentEnd(from, to int) int {
+	for to > from && strings.TrimSpace(n.lines[to-1]) == "" {
+		to--
+	}
+
+	return to
+}
+
+func (n *note) insertAt(pos int, lines []string) {
+	n.replaceLines(pos, pos, lines)
+}
+
+// replaceText replaces the text lines old of an item with the text lines of
+// the item in lines. It returns the index of the line after the new text, or
+// -1 when the note has no such item.
+func (n *note) replaceText(old, lines []string) int {
+	i := n.indexOf(old)
+	if i < 0 {
+		return -1
+	}
+
+	textEnd := itemTextEnd(lines)
+	n.replaceLines(i, i+len(old), lines[:textEnd])
+
+	return i + textEnd
+}
+
+// replaceItem replaces the text lines old of an item with the item in lines,
+// adding the sub-items the note does not have yet and keeping the ones
+// already there.
+func (n *note) replaceItem(old, lines []string) {
+	end := n.replaceText(old, lines)
+	if end < 0 {
+		return
+	}
+
+	n.addSubItemsAt(end-itemTextEnd(lines), lines)
+}
+
+// addSubItems adds the sub-items of the item in lines, e.g. a note written
+// on the device, that the item with the text lines old does not have yet.
+// It returns the number of lines added.
+func (n *note) addSubItems(old, lines []string) int {
+	i := n.indexOf(old)
+	if i < 0 {
+		return 0
+	}
+
+	return n.addSubItemsAt(i, lines)
+}
+
+// addSubItemsAt is addSubItems for the item starting at lines[i].
+func (n *note) addSubItemsAt(i int, lines []string) int {
+	items := n.items(i, len(n.lines))
+	if len(items) == 0 {
+		return 0
+	}
+	item := items[0]
+
+	added := 0
+	for _, l := range lines[itemTextEnd(lines):] {
+		if !containsLine(n.lines[item.start:item.end+added], l) {
+			n.insertAt(item.textEnd+added, []string{l})
+			added++
+		}
+	}
+
+	return added
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addBlockID appends the block ID to the last of the text lines old of an
+// item.
+func (n *note) addBlockID(old []string, id string) {
+	i := n.indexOf(old)
+	if i < 0 || id == "" {
+		return
+	}
+
+	last := i + len(old) - 1
+	n.lines[last] = strings.TrimRight(n.lines[last], " \r") + " ^" + id
+}
+
+// itemTextEnd returns the number of text lines of the item in lines.
+func itemTextEnd(lines []string) int {
+	items := (&note{lines: lines}).items(0, len(lines))
+	if len(items) == 0 {
+		return len(lines)
+	}
+
+	return items[0].textEnd
+}
+
+// indexOf returns the index of the first occurrence of lines in the note,
+// or -1.
+func (n *note) indexOf(lines []string) int {
+	if len(lines) == 0 {
+		return -1
+	}
+
+	for i := 0; i+len(lines) <= len(n.lines); i++ {
+		match := true
+		for j, l := range lines {
+			if n.lines[i+j] != l {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+
+	return -1
+}
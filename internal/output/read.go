@@ -1,46 +1,402 @@
 package output
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/storage"
+	"github.com/nsr888/kindle-highlights-to-obsidian/pkg/filename"
 	"github.com/nsr888/kindle-highlights-to-obsidian/pkg/hashs"
 )
 
-func ReadExistingExport(outputDir string) (map[string]map[string]struct{}, error) {
-	files, err := filepath.Glob(outputDir + "/*.md")
+// ExistingHighlight is a highlight found in an exported note.
+type ExistingHighlight struct {
+	// Lines are the text lines of the list item or blockquote, without its
+	// sub-items.
+	Lines     []string
+	Highlight model.Highlight
+}
+
+// ExistingNote is an exported note found in the output directory.
+type ExistingNote struct {
+	// Path is the path of the note, the output directory included.
+	Path string
+	// BookID is the kindle_book_id property of the note, empty in notes
+	// written before it existed.
+	BookID string
+	// Highlights are keyed by text hash.
+	Highlights map[string]ExistingHighlight
+	// IDs are the highlights with a block ID keyed by it.
+	IDs map[string]ExistingHighlight
+
+	// fingerprints are the highlights keyed by hashs.Fingerprint, computed
+	// by Lookup when first needed.
+	fingerprints map[string]ExistingHighlight
+	// items are the highlights in the order of the note.
+	items []ExistingHighlight
+	// simHashes are the SimHashes of the items without block ID, computed
+	// by Similar when first needed.
+	simHashes map[int]uint64
+}
+
+// Lookup returns the item of h by its ID or, for items written without one,
+// by its text, ignoring case, punctuation and whitespace.
+func (n *ExistingNote) Lookup(h model.Highlight) (ExistingHighlight, bool) {
+	if n == nil {
+		return ExistingHighlight{}, false
+	}
+
+	if e, ok := n.IDs[h.ID]; ok && h.ID != "" {
+		return e, true
+	}
+
+	if e, ok := n.Highlights[hashs.FNV64a(h.Text)]; ok {
+		return e, true
+	}
+
+	if n.fingerprints == nil {
+		n.fingerprints = make(map[string]ExistingHighlight, len(n.items))
+		for _, e := range n.items {
+			fp := hashs.Fingerprint(e.Highlight.Text)
+			if _, exists := n.fingerprints[fp]; !exists {
+				n.fingerprints[fp] = e
+			}
+		}
+	}
+
+	e, ok := n.fingerprints[hashs.Fingerprint(h.Text)]
+
+	return e, ok
+}
+
+// simHashScreen is the SimHash similarity below which items are not compared
+// with EditSimilarity. SimHash overrates similarity, so it only screens out
+// unrelated texts, which score around 0.5.
+const simHashScreen = 0.7
+
+// Similar returns the item without block ID whose text is the most similar
+// to the text of h, if their EditSimilarity is at least threshold. It finds
+// highlights whose text was edited in the note. Items at another position
+// than h are left out, of equally similar items the first one wins.
+func (n *ExistingNote) Similar(h model.Highlight, threshold float64) (ExistingHighlight, bool) {
+	if n == nil || h.Text == "" {
+		return ExistingHighlight{}, false
+	}
+
+	if n.simHashes == nil {
+		n.simHashes = make(map[int]uint64)
+		for i, e := range n.items {
+			if e.Highlight.ID == "" {
+				n.simHashes[i] = hashs.SimHash(e.Highlight.Text)
+			}
+		}
+	}
+	if len(n.simHashes) == 0 {
+		return ExistingHighlight{}, false
+	}
+
+	simHash := hashs.SimHash(h.Text)
+	length := utf8.RuneCountInString(h.Text)
+
+	var best ExistingHighlight
+	bestSimilarity := threshold
+	found := false
+	for i, e := range n.items {
+		itemHash, ok := n.simHashes[i]
+		if !ok || !overlapping(h, e.Highlight) ||
+			hashs.Similarity(simHash, itemHash) < simHashScreen {
+			continue
+		}
+
+		// the edit distance is at least the difference in length
+		itemLength := utf8.RuneCountInString(e.Highlight.Text)
+		if float64(min(length, itemLength)) < threshold*float64(max(length, itemLength)) {
+			continue
+		}
+
+		s := hashs.EditSimilarity(h.Text, e.Highlight.Text)
+		if s > bestSimilarity || !found && s >= bestSimilarity {
+			best, bestSimilarity, found = e, s, true
+		}
+	}
+
+	return best, found
+}
+
+// overlapping reports whether the positions of h and o overlap, or are not
+// known for both: notes show the location, or the page for PDFs.
+func overlapping(h, o model.Highlight) bool {
+	switch {
+	case !h.Location.IsZero() && !o.Location.IsZero():
+		return h.Location.Overlaps(o.Location)
+	case !h.Page.IsZero() && !o.Page.IsZero():
+		return h.Page.Overlaps(o.Page)
+	default:
+		return true
+	}
+}
+
+// ExistingExport indexes the notes of the output directory by book ID and,
+// for notes without one, by filename, so that notes are found wherever they
+// were moved or however they were renamed.
+type ExistingExport struct {
+	byID   map[string]*ExistingNote
+	byName map[string]*ExistingNote
+}
+
+// Find returns the note of book.
+func (e ExistingExport) Find(book model.Book) (*ExistingNote, bool) {
+	if n, ok := e.byID[book.ID]; ok {
+		return n, true
+	}
+
+	if n, ok := e.byName[book.Filename]; ok {
+		return n, true
+	}
+
+	// notes written by earlier versions, which dropped more characters
+	n, ok := e.byName[filename.Legacy(book.Info)]
+
+	return n, ok
+}
+
+// ReadExistingExport reads the notes in outputDir and its subdirectories,
+// skipping hidden ones like ".obsidian" or ".trash". Notes are read
+// concurrently, the result does not depend on the order they are read in.
+func ReadExistingExport(outputDir string) (ExistingExport, error) {
+	paths, err := notePaths(outputDir)
+	if err != nil {
+		return ExistingExport{}, fmt.Errorf("walk output directory: %w", err)
+	}
+
+	notes, err := readExistingNotes(paths)
 	if err != nil {
-		return nil, fmt.Errorf("glob failed: %w", err)
+		return ExistingExport{}, err
 	}
 
-	hashMap := make(map[string]map[string]struct{}, len(files))
-	for _, file := range files {
-		fd, err := os.Open(file)
+	export := ExistingExport{
+		byID:   make(map[string]*ExistingNote),
+		byName: make(map[string]*ExistingNote),
+	}
+	for _, n := range notes {
+		if n.BookID == "" {
+			name := filepath.Base(n.Path)
+			if _, exists := export.byName[name]; !exists {
+				export.byName[name] = n
+			}
+			continue
+		}
+
+		if other, exists := export.byID[n.BookID]; exists {
+			fmt.Println("Found more than one note for book", n.BookID+":", other.Path, "and", n.Path)
+			continue
+		}
+		export.byID[n.BookID] = n
+	}
+
+	if len(notes) > 0 {
+		fmt.Println("Found", len(notes), "existing books in output directory", outputDir)
+	}
+
+	return export, nil
+}
+
+// notePaths returns the Markdown files below outputDir in lexical order.
+func notePaths(outputDir string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return nil, fmt.Errorf("open file: %w", err)
+			if path == outputDir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if path != outputDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
-		defer fd.Close()
+		if !d.IsDir() && filepath.Ext(path) == ".md" {
+			paths = append(paths, path)
+		}
+
+		return nil
+	})
+
+	return paths, err
+}
 
-		basename := filepath.Base(file)
-		hashMap[basename] = make(map[string]struct{})
+// readExistingNotes reads the notes at paths with one worker per CPU.
+func readExistingNotes(paths []string) ([]*ExistingNote, error) {
+	notes := make([]*ExistingNote, len(paths))
+	errs := make([]error, len(paths))
 
-		br := bufio.NewReader(fd)
-		scanner := bufio.NewScanner(br)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.HasPrefix(line, "- ") {
-				hash := hashs.FNV64a(strings.TrimSpace(line[2:]))
-				hashMap[basename][hash] = struct{}{}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.NumCPU(), len(paths)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				notes[i], errs[i] = readExistingNote(paths[i])
 			}
+		}()
+	}
+
+	for i := range paths {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("read note %s: %w", paths[i], err)
 		}
 	}
 
-	if len(hashMap) > 0 {
-		fmt.Println("Found", len(hashMap), "existing books in output directory", outputDir)
+	return notes, nil
+}
+
+// readExistingNote indexes the highlights of the note at path. Only the
+// highlights section is looked at when the note has one.
+func readExistingNote(path string) (*ExistingNote, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	n := &ExistingNote{
+		Path:       path,
+		Highlights: make(map[string]ExistingHighlight),
+		IDs:        make(map[string]ExistingHighlight),
+	}
+
+	doc := parseNote(string(content))
+	start, end := 0, len(doc.lines)
+	if fm, bodyStart, ok := splitFrontmatter(doc.lines); ok {
+		n.BookID = parseFrontmatter(fm).value(bookIDProperty)
+		start = bodyStart
+	}
+	if from, to, ok := doc.highlightsSection(); ok {
+		start, end = from, to
+	}
+
+	for _, item := range doc.items(start, end) {
+		e := ExistingHighlight{
+			Lines:     doc.lines[item.start:item.textEnd],
+			Highlight: item.highlight,
+		}
+		n.items = append(n.items, e)
+		n.Highlights[hashs.FNV64a(e.Highlight.Text)] = e
+		if e.Highlight.ID != "" {
+			n.IDs[e.Highlight.ID] = e
+		}
+	}
+
+	return n, nil
+}
+
+// parseHighlightText turns "text (loc. 1293-1294) ^id", the text of a list
+// item or blockquote, back into a highlight, see escapeMarkdown.
+func parseHighlightText(text string) model.Highlight {
+	text = strings.TrimSpace(storage.NormalizeText(text))
+
+	text, id, _ := cutBlockID(text)
+	text, kind, r, _ := cutPosition(text)
+
+	h := model.Highlight{ID: id, Text: unescapeMarkdown(text)}
+	switch kind {
+	case "loc":
+		h.Location = r
+	case "p":
+		h.Page = r
 	}
 
-	return hashMap, nil
+	return h
+}
+
+// cutBlockID cuts the Obsidian block ID, " ^id", off the end of text. It is
+// parsed by hand, as running a regexp on every text of a vault is slow.
+func cutBlockID(text string) (rest, id string, ok bool) {
+	i := strings.LastIndexByte(text, '^')
+	if i < 0 || i == len(text)-1 {
+		return text, "", false
+	}
+
+	for _, c := range text[i+1:] {
+		if !isASCIIAlnum(c) && c != '-' {
+			return text, "", false
+		}
+	}
+
+	rest = strings.TrimRight(text[:i], " \t\n\f\r")
+	if len(rest) == i {
+		return text, "", false
+	}
+
+	return rest, text[i+1:], true
+}
+
+// cutPosition cuts the " (loc. 1293-1294)" or " (p. 12)" suffix written by
+// the templates off the end of text, so that highlights hash the same with
+// and without it. kind is "loc" or "p".
+func cutPosition(text string) (rest, kind string, r model.Range, ok bool) {
+	if !strings.HasSuffix(text, ")") {
+		return text, "", r, false
+	}
+
+	i := strings.LastIndex(text, " (")
+	if i < 0 {
+		return text, "", r, false
+	}
+
+	kind, nums, found := strings.Cut(text[i+2:len(text)-1], ". ")
+	if !found || kind != "loc" && kind != "p" {
+		return text, "", r, false
+	}
+
+	start, end, hasEnd := strings.Cut(nums, "-")
+	if r.Start, ok = parseDigits(start); !ok {
+		return text, "", model.Range{}, false
+	}
+	r.End = r.Start
+	if hasEnd {
+		if r.End, ok = parseDigits(end); !ok {
+			return text, "", model.Range{}, false
+		}
+	}
+
+	return text[:i], kind, r, true
+}
+
+// parseDigits parses s if it consists of ASCII digits only.
+func parseDigits(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+
+	return n, err == nil
+}
+
+func isASCIIAlnum(c rune) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
 }
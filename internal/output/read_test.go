@@ -0,0 +1,133 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+// BenchmarkReadExistingExport reads a vault of 5,000 notes with 50
+// highlights each, which should take well below a second.
+func BenchmarkReadExistingExport(b *testing.B) {
+	dir := b.TempDir()
+	writeVault(b, dir, 5000, 50)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadExistingExport(dir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func writeVault(tb testing.TB, dir string, notes, highlights int) {
+	tb.Helper()
+
+	tmpl, err := LoadTemplate(DefaultTemplate, "")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	date := time.Date(2013, 12, 1, 19, 49, 48, 0, time.UTC)
+	for n := 0; n < notes; n++ {
+		title := fmt.Sprintf("Book %d", n)
+		book := model.Book{
+			ID:       model.BookID(title, []string{"Author"}),
+			Title:    title,
+			Author:   "Author",
+			Authors:  []string{"Author"},
+			Filename: title + ".md",
+		}
+		for h := 0; h < highlights; h++ {
+			book.Highlights = append(book.Highlights, model.Highlight{
+				Date:     date,
+				Text:     fmt.Sprintf("Highlight %d of %s, a sentence of about the length highlights usually have.", h, title),
+				Location: model.Range{Start: 100 * (h + 1), End: 100*(h+1) + 2},
+			})
+		}
+		book.SetHighlightIDs()
+
+		content, err := renderBook(tmpl, book, date)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, book.Filename), []byte(content), fileMode)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestExistingNoteSimilar(t *testing.T) {
+	note := readTestNote(t, strings.Join([]string{
+		"<!-- kindle-highlights:start -->",
+		"- The map is the territory. (loc. 100-101)",
+		"- The quick brown fox jumps over the lazy cat (loc. 200-201)",
+		"- The quick brown fox jumps over the lazy cat (loc. 300-301)",
+		"- Already synced text (loc. 400-401) ^abc",
+		"<!-- kindle-highlights:end -->",
+	}, "\n"))
+
+	tests := []struct {
+		name string
+		h    model.Highlight
+		want string
+	}{
+		{
+			name: "word fixed",
+			h:    model.Highlight{Text: "The quick brown fox jumps over the lazy dog", Location: model.Range{Start: 200, End: 201}},
+			want: "200",
+		},
+		{
+			name: "unknown position, first item wins",
+			h:    model.Highlight{Text: "The quick brown fox jumps over the lazy dog"},
+			want: "200",
+		},
+		{
+			name: "other position",
+			h:    model.Highlight{Text: "The quick brown fox jumps over the lazy dog", Location: model.Range{Start: 500}},
+		},
+		{
+			name: "negation",
+			h:    model.Highlight{Text: "The map is not the territory.", Location: model.Range{Start: 100, End: 101}},
+		},
+		{
+			name: "item with block ID",
+			h:    model.Highlight{Text: "Already synced texts", Location: model.Range{Start: 400, End: 401}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := note.Similar(tt.h, DefaultSimilarity)
+			var got string
+			if ok {
+				got = fmt.Sprint(e.Highlight.Location.Start)
+			}
+			if got != tt.want {
+				t.Errorf("Similar() found item at %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func readTestNote(t *testing.T, content string) *ExistingNote {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "note.md")
+	if err := os.WriteFile(path, []byte(content), fileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := readExistingNote(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return note
+}
@@ -0,0 +1,117 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const stateFilename = ".kindle-highlights-sync.json"
+
+// SyncState remembers every highlight ever exported to the output
+// directory, so that highlights deleted from a note are not added again on
+// the next sync.
+type SyncState struct {
+	Books map[string]*BookState `json:"books"`
+}
+
+type BookState struct {
+	Title string `json:"title"`
+	// Highlights are the IDs of the exported highlights, or the text hashes
+	// of the ones exported before highlights had IDs.
+	Highlights []string `json:"highlights"`
+
+	set map[string]struct{}
+}
+
+// ReadSyncState reads the state file of outputDir. A missing file yields an
+// empty state.
+func ReadSyncState(outputDir string) (*SyncState, error) {
+	s := &SyncState{Books: make(map[string]*BookState)}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, stateFilename))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+
+	err = json.Unmarshal(content, s)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal state: %w", err)
+	}
+	if s.Books == nil {
+		s.Books = make(map[string]*BookState)
+	}
+
+	return s, nil
+}
+
+// Save writes the state file of outputDir.
+func (s *SyncState) Save(outputDir string) error {
+	for _, b := range s.Books {
+		sort.Strings(b.Highlights)
+	}
+
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(outputDir, stateFilename), append(content, '\n'), fileMode)
+	if err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+
+	return nil
+}
+
+// Exported reports whether the highlight was exported for the book before.
+func (s *SyncState) Exported(bookID, id string) bool {
+	b, ok := s.Books[bookID]
+	if !ok {
+		return false
+	}
+
+	_, ok = b.index()[id]
+
+	return ok
+}
+
+// Add records the highlight as exported for the book.
+func (s *SyncState) Add(bookID, title, id string) {
+	b, ok := s.Books[bookID]
+	if !ok {
+		b = &BookState{}
+		s.Books[bookID] = b
+	}
+	b.Title = title
+
+	if _, exists := b.index()[id]; exists {
+		return
+	}
+	b.set[id] = struct{}{}
+	b.Highlights = append(b.Highlights, id)
+}
+
+// Forget drops everything recorded for the book, so that highlights deleted
+// from its note are exported again.
+func (s *SyncState) Forget(bookID string) {
+	delete(s.Books, bookID)
+}
+
+func (b *BookState) index() map[string]struct{} {
+	if b.set == nil {
+		b.set = make(map[string]struct{}, len(b.Highlights))
+		for _, h := range b.Highlights {
+			b.set[h] = struct{}{}
+		}
+	}
+
+	return b.set
+}
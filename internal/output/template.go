@@ -0,0 +1,140 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/templates"
+)
+
+const (
+	// DefaultTemplate is the template set used when none is chosen.
+	DefaultTemplate = "obsidian"
+
+	// entryTemplate is the template a note is rendered from in a directory
+	// of templates. The other files can hold templates it uses.
+	entryTemplate = "note.tmpl"
+
+	// highlightTemplate renders a single highlight. It is defined in
+	// "partials" for all sets, which can define their own, as are the
+	// styles "highlight-<style>".
+	highlightTemplate = "highlight"
+	// highlightsTemplate renders the highlights region.
+	highlightsTemplate = "highlights"
+
+	templateExt = ".tmpl"
+)
+
+// Styles are the ways of rendering highlights a template can be switched
+// to, see LoadTemplate.
+var Styles = []string{"list", "blockquote", "callout", "table"}
+
+// TemplateNames returns the names of the built-in template sets.
+func TemplateNames() []string {
+	files, _ := fs.Glob(templates.FS, "*"+templateExt)
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(f, templateExt))
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// LoadTemplate returns the built-in template set called name or, when name
+// is not one of them, the template file or the directory of templates at
+// path name. An empty name selects DefaultTemplate. The templates in
+// "partials" are defined before, so that user templates only need to define
+// what they change. A style other than "" renders the highlights in that
+// style instead of the way the template does.
+func LoadTemplate(name, style string) (*template.Template, error) {
+	tmpl, err := loadTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if style == "" {
+		return tmpl, nil
+	}
+	if tmpl.Lookup(highlightTemplate+"-"+style) == nil {
+		return nil, fmt.Errorf("unknown style %s, available are: %s", style, strings.Join(Styles, ", "))
+	}
+
+	for _, t := range []string{highlightTemplate, highlightsTemplate} {
+		_, err = tmpl.New(t).Parse(`{{ template "` + t + "-" + style + `" . }}`)
+		if err != nil {
+			return nil, fmt.Errorf("parse style: %w", err)
+		}
+	}
+
+	return tmpl, nil
+}
+
+func loadTemplate(name string) (*template.Template, error) {
+	if name == "" {
+		name = DefaultTemplate
+	}
+
+	builtin := name + templateExt
+	if _, err := fs.Stat(templates.FS, builtin); err == nil {
+		tmpl, err := newTemplate(builtin)
+		if err == nil {
+			_, err = tmpl.ParseFS(templates.FS, builtin)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse template %s: %w", name, err)
+		}
+		return tmpl, nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unknown template %s, built-in are: %s",
+				name, strings.Join(TemplateNames(), ", "))
+		}
+		return nil, fmt.Errorf("stat template: %w", err)
+	}
+
+	if !info.IsDir() {
+		tmpl, err := newTemplate(filepath.Base(name))
+		if err == nil {
+			_, err = tmpl.ParseFiles(name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse template: %w", err)
+		}
+		return tmpl, nil
+	}
+
+	tmpl, err := newTemplate(entryTemplate)
+	if err == nil {
+		_, err = tmpl.ParseGlob(filepath.Join(name, "*"+templateExt))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse templates: %w", err)
+	}
+	if tmpl.Lookup(entryTemplate) == nil {
+		return nil, fmt.Errorf("template directory %s has no %s", name, entryTemplate)
+	}
+
+	return tmpl, nil
+}
+
+// newTemplate returns the template called name with the functions and the
+// partials defined.
+func newTemplate(name string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcMap).ParseFS(templates.FS, "partials/*"+templateExt)
+	if err != nil {
+		return nil, fmt.Errorf("parse partials: %w", err)
+	}
+
+	return tmpl, nil
+}
@@ -1,109 +1,432 @@
 package output
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
+	"time"
 
-	"github.com/nsr888/kindle-highlights-to-obsidian/pkg/hashs"
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+	"github.com/nsr888/kindle-highlights-to-obsidian/pkg/filename"
+	"github.com/nsr888/kindle-highlights-to-obsidian/pkg/hashs"
 )
 
 const (
 	fileMode = 0644
 )
 
+type Options struct {
+	// Rerender regenerates the managed parts of existing notes, the
+	// highlights region and the managed frontmatter properties, from the
+	// template instead of only adding new highlights.
+	Rerender bool
+	// Forget drops what the sync state recorded for the books, so that
+	// highlights deleted from their notes are exported again.
+	Forget bool
+	// Similarity is the edit similarity from which a highlight in a note
+	// is taken as an edited version of a new one, see ExistingNote.Similar.
+	// Zero means DefaultSimilarity.
+	Similarity float64
+	// Template renders the notes, see LoadTemplate. Nil means
+	// DefaultTemplate in its own style.
+	Template *template.Template
+}
+
+// DefaultSimilarity tolerates a few fixed typos in highlights of a sentence
+// or more.
+const DefaultSimilarity = 0.9
+
 func WriteBooks(
 	outputDir string,
 	books []model.Book,
-	existingClippings map[string]map[string]struct{},
+	existingClippings ExistingExport,
+	opts Options,
 ) error {
 	err := os.MkdirAll(outputDir, fs.ModePerm)
 	if err != nil {
 		return fmt.Errorf("create output directory: %w", err)
 	}
 
+	state, err := ReadSyncState(outputDir)
+	if err != nil {
+		return fmt.Errorf("read sync state: %w", err)
+	}
+
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl, err = LoadTemplate(DefaultTemplate, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	similarity := opts.Similarity
+	if similarity == 0 {
+		similarity = DefaultSimilarity
+	}
+
+	syncedAt := time.Now()
+
 	for _, values := range books {
-		if _, exists := existingClippings[values.Filename]; exists {
-			err := appendClippings(outputDir, values, existingClippings[values.Filename])
+		if opts.Forget {
+			state.Forget(values.ID)
+		}
+
+		note, exists := existingClippings.Find(values)
+		if exists {
+			values = dropDeleted(values, state, note, similarity)
+		}
+
+		switch {
+		case exists && opts.Rerender:
+			err = rerenderBook(tmpl, note.Path, values, syncedAt)
+			if errors.Is(err, errProseBetweenItems) {
+				// leave the user's text alone, only add new highlights
+				fmt.Println("Not rerendering", note.Path+":", err.Error()+", add the markers around them to rerender it")
+				err = mergeHighlights(tmpl, note, values, similarity, syncedAt)
+			}
+			if err != nil {
+				return fmt.Errorf("rerender book: %w", err)
+			}
+		case exists:
+			err = mergeHighlights(tmpl, note, values, similarity, syncedAt)
+			if err != nil {
+				return fmt.Errorf("merge highlights: %w", err)
+			}
+		default:
+			err = writeBook(tmpl, outputDir, values, syncedAt)
 			if err != nil {
-				return fmt.Errorf("append clippings: %w", err)
+				return fmt.Errorf("write all clippings: %w", err)
 			}
+		}
+
+		recordExported(state, values, note)
+	}
+
+	err = state.Save(outputDir)
+	if err != nil {
+		return fmt.Errorf("save sync state: %w", err)
+	}
+
+	return nil
+}
 
+// dropDeleted leaves out the highlights that were exported before but are
+// no longer in the note, as the user deleted them.
+func dropDeleted(
+	book model.Book,
+	state *SyncState,
+	existing *ExistingNote,
+	similarity float64,
+) model.Book {
+	highlights := make([]model.Highlight, 0, len(book.Highlights))
+	for _, h := range book.Highlights {
+		// states written before highlights had IDs have text hashes
+		exported := state.Exported(book.ID, h.ID) ||
+			state.Exported(book.ID, hashs.FNV64a(h.Text))
+		if exported && !inNote(existing, h, similarity) {
+			fmt.Println("Skipped deleted highlight", h.ID, "in", existing.Path)
 			continue
 		}
+		highlights = append(highlights, h)
+	}
+	book.Highlights = highlights
 
-		err := WriteBook(outputDir, values)
-		if err != nil {
-			return fmt.Errorf("write all clippings: %w", err)
+	return book
+}
+
+func inNote(existing *ExistingNote, h model.Highlight, similarity float64) bool {
+	if _, ok := existing.Lookup(h); ok {
+		return true
+	}
+	_, ok := existing.Similar(h, similarity)
+
+	return ok
+}
+
+// recordExported adds the highlights of book and the ones already in its
+// note, which is nil for new notes, to the sync state.
+func recordExported(
+	state *SyncState,
+	book model.Book,
+	existing *ExistingNote,
+) {
+	for _, h := range book.Highlights {
+		if h.Text != "" {
+			state.Add(book.ID, book.Title, h.ID)
 		}
 	}
 
-	return nil
+	if existing == nil {
+		return
+	}
+	for hash, e := range existing.Highlights {
+		if e.Highlight.ID != "" {
+			hash = e.Highlight.ID
+		}
+		state.Add(book.ID, book.Title, hash)
+	}
 }
 
-func appendClippings(
-	outputDir string,
+// mergeHighlights inserts the new highlights of book into the highlights
+// section of its existing note, in book order, and replaces stale revisions
+// of extended highlights. The managed frontmatter properties are updated,
+// everything else in the note is kept as it is.
+func mergeHighlights(
+	tmpl *template.Template,
+	existing *ExistingNote,
 	book model.Book,
-	existingClippings map[string]struct{},
+	similarity float64,
+	syncedAt time.Time,
 ) error {
-	filePath := filepath.Join(outputDir, book.Filename)
-	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, fileMode)
+	rendered, err := renderBook(tmpl, book, syncedAt)
 	if err != nil {
-		return fmt.Errorf("open file: %w", err)
+		return fmt.Errorf("render book: %w", err)
 	}
-	defer f.Close()
 
-	fmt.Println("Found", len(book.Highlights), "highlights in", book.Filename)
+	filePath := existing.Path
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	fmt.Println("Found", len(book.Highlights), "highlights in", filePath)
+
+	n := parseNote(string(content))
+
+	// rows inserted elsewhere than in a table need a header, the one of the
+	// note's table or else the one of the template
+	header, ok := n.tableHeader()
+	if !ok {
+		header, _ = parseNote(rendered).tableHeader()
+	}
 
 	cnt := 0
 	for _, highlight := range book.Highlights {
-		hash := hashs.FNV64a(highlight.Text)
-		if _, exists := existingClippings[hash]; exists {
-			cnt++
+		if highlight.Text == "" {
+			// nothing but the clipping limit message
 			continue
 		}
 
-		_, err = f.WriteString("- " + highlight.Text + "\n")
+		lines, err := renderHighlight(tmpl, highlight)
 		if err != nil {
-			return fmt.Errorf("write string: %w", err)
+			return fmt.Errorf("render highlight: %w", err)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		if e, ok := existing.Lookup(highlight); ok {
+			if e.Highlight.ID == highlight.ID && e.Highlight.Text != highlight.Text &&
+				strings.Contains(highlight.Text, e.Highlight.Text) {
+				n.replaceItem(e.Lines, lines)
+				fmt.Println("Replaced highlight", highlight.ID, "in", filePath)
+				continue
+			}
+
+			// e.g. a note written on the device after the highlight was synced
+			if n.addSubItems(e.Lines, lines) > 0 {
+				fmt.Println("Added note to highlight", highlight.ID, "in", filePath)
+			}
+
+			switch {
+			case e.Highlight.ID == "" && e.Highlight.Text == highlight.Text:
+				// exported before highlights had IDs
+				n.replaceText(e.Lines, lines)
+			case e.Highlight.ID == "":
+				n.addBlockID(e.Lines, highlight.ID)
+			}
+			// the same text or edited by the user
+			cnt++
+			continue
 		}
-		fmt.Println("Appended highlight with hash", hash, "to", book.Filename)
+
+		if stale, edited, ok := findStale(highlight, existing.Highlights); ok {
+			if edited {
+				n.addBlockID(stale.Lines, highlight.ID)
+				cnt++
+				continue
+			}
+
+			n.replaceItem(stale.Lines, lines)
+			fmt.Println("Replaced highlight", highlight.ID, "in", filePath)
+			continue
+		}
+
+		if e, ok := existing.Similar(highlight, similarity); ok {
+			// edited by the user, keep their text
+			n.addBlockID(e.Lines, highlight.ID)
+			cnt++
+			continue
+		}
+
+		n.insertHighlight(highlight, lines, header)
+		fmt.Println("Added highlight", highlight.ID, "to", filePath)
 	}
 
+	n.replaceFrontmatter(parseNote(rendered))
+
 	if cnt > 0 {
-		fmt.Println("Skipped", cnt, "highlights in", book.Filename)
+		fmt.Println("Skipped", cnt, "highlights in", filePath)
+	}
+
+	err = os.WriteFile(filePath, []byte(n.String()), fileMode)
+	if err != nil {
+		return fmt.Errorf("write file: %w", err)
 	}
 
 	return nil
 }
 
+// findStale looks for an earlier revision of highlight among the items
+// without block ID, e.g. the shorter text exported before the highlight was
+// extended on the device. A match at the very same position whose text
+// contains the new one, e.g. a highlight shortened on the device after the
+// text was completed in the note, is left as is and reported as edited.
+func findStale(
+	highlight model.Highlight,
+	existingClippings map[string]ExistingHighlight,
+) (stale ExistingHighlight, edited, ok bool) {
+	for _, e := range existingClippings {
+		if e.Highlight.ID != "" || !highlight.SameAs(e.Highlight) {
+			continue
+		}
+
+		// notes show the page only for highlights without location
+		samePosition := highlight.Location == e.Highlight.Location &&
+			(!highlight.Location.IsZero() || highlight.Page == e.Highlight.Page)
+		edited = samePosition && !strings.Contains(highlight.Text, e.Highlight.Text)
+
+		return e, edited, true
+	}
+
+	return ExistingHighlight{}, false, false
+}
+
+// renderHighlight renders the lines of h with the highlight template, the
+// same way as in new notes.
+func renderHighlight(tmpl *template.Template, h model.Highlight) ([]string, error) {
+	var sb strings.Builder
+	err := tmpl.ExecuteTemplate(&sb, highlightTemplate, h)
+	if err != nil {
+		return nil, fmt.Errorf("execute template: %w", err)
+	}
+
+	content := strings.Trim(sb.String(), "\n")
+	if content == "" {
+		return nil, nil
+	}
+
+	return strings.Split(content, "\n"), nil
+}
+
 func WriteBook(
 	outputDir string,
 	book model.Book,
 ) error {
-	tmplDir := "./templates"
-	tmplPath := filepath.Join(tmplDir, "obsidian.tmpl")
-	baseFile := filepath.Base(tmplPath)
+	tmpl, err := LoadTemplate(DefaultTemplate, "")
+	if err != nil {
+		return err
+	}
+
+	return writeBook(tmpl, outputDir, book, time.Now())
+}
 
-	tmpl, err := template.New(baseFile).ParseFiles(tmplPath)
+func writeBook(
+	tmpl *template.Template,
+	outputDir string,
+	book model.Book,
+	syncedAt time.Time,
+) error {
+	content, err := renderBook(tmpl, book, syncedAt)
 	if err != nil {
-		return fmt.Errorf("parse template: %w", err)
+		return fmt.Errorf("render book: %w", err)
 	}
 
+	// a file of that name may belong to another book, e.g. one exported
+	// from another device
 	filePath := filepath.Join(outputDir, book.Filename)
-	f, err := os.Create(filePath)
+	err = createFile(filePath, content)
+	if errors.Is(err, fs.ErrExist) {
+		filePath = filepath.Join(outputDir, filename.Disambiguate(book.Filename, book.ID))
+		fmt.Println(book.Filename, "exists, writing", filePath)
+		err = createFile(filePath, content)
+	}
 	if err != nil {
 		return fmt.Errorf("create file: %w", err)
 	}
-	defer f.Close()
 
-	err = tmpl.Execute(f, book)
+	return nil
+}
+
+// createFile writes content to a new file at filePath, failing with
+// fs.ErrExist instead of overwriting an existing one.
+func createFile(filePath, content string) error {
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fileMode)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(content)
+	if errC := f.Close(); err == nil {
+		err = errC
+	}
+
+	return err
+}
+
+// rerenderBook renders book and puts the managed parts of the result into
+// its existing note at filePath, leaving everything outside them untouched.
+func rerenderBook(
+	tmpl *template.Template,
+	filePath string,
+	book model.Book,
+	syncedAt time.Time,
+) error {
+	rendered, err := renderBook(tmpl, book, syncedAt)
 	if err != nil {
-		return fmt.Errorf("execute template: %w", err)
+		return fmt.Errorf("render book: %w", err)
 	}
 
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	n := parseNote(string(content))
+	r := parseNote(rendered)
+	if err := n.replaceRegion(r); err != nil {
+		return err
+	}
+	n.replaceFrontmatter(r)
+
+	err = os.WriteFile(filePath, []byte(n.String()), fileMode)
+	if err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	fmt.Println("Rerendered", filePath)
+
 	return nil
 }
+
+// templateData is what templates are executed with: the book and the time
+// of the sync.
+type templateData struct {
+	model.Book
+	SyncedAt time.Time
+}
+
+func renderBook(tmpl *template.Template, book model.Book, syncedAt time.Time) (string, error) {
+	var sb strings.Builder
+	err := tmpl.Execute(&sb, templateData{Book: book, SyncedAt: syncedAt})
+	if err != nil {
+		return "", fmt.Errorf("execute template: %w", err)
+	}
+
+	return sb.String(), nil
+}
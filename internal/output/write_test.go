@@ -0,0 +1,188 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+// testBook returns a book with a highlight per text, at locations 100, 200
+// and so on.
+func testBook(texts ...string) model.Book {
+	book := model.Book{
+		ID:       model.BookID("Book", []string{"Author"}),
+		Title:    "Book",
+		Author:   "Author",
+		Authors:  []string{"Author"},
+		Filename: "Book - Author.md",
+	}
+	for i, text := range texts {
+		book.Highlights = append(book.Highlights, model.Highlight{
+			Date:     time.Date(2013, 12, 1, 19, 49, 48, 0, time.UTC),
+			Text:     text,
+			Location: model.Range{Start: 100 * (i + 1), End: 100*(i+1) + 1},
+		})
+	}
+	book.SetHighlightIDs()
+
+	return book
+}
+
+// syncBook writes book to dir as a run of the tool does and returns the note.
+func syncBook(t *testing.T, dir string, book model.Book, opts Options) string {
+	t.Helper()
+
+	existing, err := ReadExistingExport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteBooks(dir, []model.Book{book}, existing, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, book.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestWriteBooksRerenderKeepsProse(t *testing.T) {
+	tests := []struct {
+		name     string
+		note     string
+		keep     []string
+		rendered bool
+	}{
+		{
+			name: "prose between highlights",
+			note: "## Highlights\n- First\n\n### My chapter heading\n\nSome commentary\n\n- Second\n",
+			keep: []string{"- First", "### My chapter heading", "Some commentary", "- Second"},
+		},
+		{
+			name:     "list only",
+			note:     "## Highlights\n- First\n\n- Second\n\nMy summary\n",
+			keep:     []string{"My summary"},
+			rendered: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			book := testBook("First", "Second")
+			err := os.WriteFile(filepath.Join(dir, book.Filename), []byte(tt.note), fileMode)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := syncBook(t, dir, book, Options{Rerender: true})
+
+			for _, line := range tt.keep {
+				if !strings.Contains(got, line) {
+					t.Errorf("note lost %q:\n%s", line, got)
+				}
+			}
+			if rendered := strings.Contains(got, regionStart); rendered != tt.rendered {
+				t.Errorf("note rendered %v, want %v:\n%s", rendered, tt.rendered, got)
+			}
+			if n := strings.Count(got, "Second"); n != 1 {
+				t.Errorf("note has %d copies of a highlight:\n%s", n, got)
+			}
+		})
+	}
+}
+
+func TestWriteBooksMergeSwitchingStyles(t *testing.T) {
+	full := testBook("Alpha", "Beta", "Gamma", "Delta")
+	first := full
+	first.Highlights = []model.Highlight{full.Highlights[0], full.Highlights[2]}
+
+	for _, from := range Styles {
+		for _, to := range Styles {
+			t.Run(from+" to "+to, func(t *testing.T) {
+				dir := t.TempDir()
+				syncBook(t, dir, first, Options{Template: testTemplate(t, from)})
+				syncBook(t, dir, full, Options{Template: testTemplate(t, to)})
+				got := syncBook(t, dir, full, Options{Template: testTemplate(t, to)})
+
+				if !strings.Contains(got, "highlight_count: 4") {
+					t.Errorf("highlight_count is not 4:\n%s", got)
+				}
+
+				note := readTestNote(t, got)
+				if len(note.IDs) != len(full.Highlights) {
+					t.Fatalf("read %d highlights, want %d:\n%s", len(note.IDs), len(full.Highlights), got)
+				}
+				for _, h := range full.Highlights {
+					e, ok := note.IDs[h.ID]
+					if !ok || e.Highlight.Text != h.Text {
+						t.Errorf("highlight %q not read back as written:\n%s", h.Text, got)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestWriteBooksMergeAddsNotes(t *testing.T) {
+	dir := t.TempDir()
+	book := testBook("Alpha", "Beta")
+	syncBook(t, dir, book, Options{})
+
+	book.Highlights[0].Note = "Written on the device"
+	for i := 0; i < 2; i++ {
+		got := syncBook(t, dir, book, Options{})
+		if n := strings.Count(got, "Written on the device"); n != 1 {
+			t.Fatalf("sync %d: note written %d times, want 1:\n%s", i+1, n, got)
+		}
+	}
+}
+
+func TestWriteBooksKeepsDeletedProperties(t *testing.T) {
+	for _, rerender := range []bool{false, true} {
+		dir := t.TempDir()
+		book := testBook("Alpha")
+		got := syncBook(t, dir, book, Options{})
+		if !strings.Contains(got, "recommended_by:") || !strings.Contains(got, "tags:") {
+			t.Fatalf("new note without template properties:\n%s", got)
+		}
+
+		// the user deletes "recommended_by" and "tags"
+		edited := strings.Replace(got, "recommended_by: \ntags:\n  - books\n", "", 1)
+		if edited == got {
+			t.Fatalf("properties not found:\n%s", got)
+		}
+		err := os.WriteFile(filepath.Join(dir, book.Filename), []byte(edited), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		book = testBook("Alpha", "Beta")
+		got = syncBook(t, dir, book, Options{Rerender: rerender})
+		if strings.Contains(got, "recommended_by:") || strings.Contains(got, "tags:") {
+			t.Errorf("rerender %v: deleted properties written again:\n%s", rerender, got)
+		}
+		if !strings.Contains(got, "highlight_count: 2") {
+			t.Errorf("rerender %v: highlight_count is not 2:\n%s", rerender, got)
+		}
+	}
+}
+
+func testTemplate(t *testing.T, style string) *template.Template {
+	t.Helper()
+
+	tmpl, err := LoadTemplate(DefaultTemplate, style)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tmpl
+}
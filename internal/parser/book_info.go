@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"strings"
+)
+
+// BookInfo parses the first line of an entry, "Title (Author)". The author
+// is the last balanced parenthesised group at the end of the line, so
+// "Thinking, Fast and Slow (Penguin Edition) (Kahneman, Daniel)" has the
+// title "Thinking, Fast and Slow (Penguin Edition)". Lines without such a
+// group are all title and have no author.
+//
+// author is returned as written by Kindle, authors are split on ";" and "&"
+// and "Last, First" is turned into "First Last". Other names with commas are
+// lists of authors, split on the commas.
+func BookInfo(s string) (title, author string, authors []string) {
+	s = strings.TrimSpace(s)
+	if !strings.HasSuffix(s, ")") {
+		return s, "", nil
+	}
+
+	depth := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		switch s[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+		}
+
+		if depth == 0 {
+			title = strings.TrimSpace(s[:i])
+			author = strings.TrimSpace(s[i+1 : len(s)-1])
+			break
+		}
+	}
+
+	if depth != 0 || title == "" {
+		// unbalanced parentheses or nothing but the group: all title
+		return s, "", nil
+	}
+
+	return title, author, splitAuthors(author)
+}
+
+func splitAuthors(author string) []string {
+	parts := strings.FieldsFunc(author, func(r rune) bool {
+		return r == ';' || r == '&'
+	})
+
+	authors := make([]string, 0, len(parts))
+	for _, p := range parts {
+		authors = append(authors, splitAuthorName(p)...)
+	}
+
+	return authors
+}
+
+// splitAuthorName turns "Kahneman, Daniel" into "Daniel Kahneman". Only
+// single words are taken for a last and a first name, other names with
+// commas, like "Daniel Kahneman, Amos Tversky", are split on the commas.
+func splitAuthorName(name string) []string {
+	parts := strings.Split(name, ",")
+	for i, p := range parts {
+		parts[i] = strings.Join(strings.Fields(p), " ")
+	}
+
+	if len(parts) == 2 && isSingleName(parts[0]) && isSingleName(parts[1]) {
+		return []string{parts[1] + " " + parts[0]}
+	}
+
+	names := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			names = append(names, p)
+		}
+	}
+
+	return names
+}
+
+func isSingleName(s string) bool {
+	return s != "" && !strings.Contains(s, " ")
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBookInfo(t *testing.T) {
+	tests := []struct {
+		line    string
+		title   string
+		author  string
+		authors []string
+	}{
+		{
+			line:    "Thinking, Fast and Slow (Kahneman, Daniel)",
+			title:   "Thinking, Fast and Slow",
+			author:  "Kahneman, Daniel",
+			authors: []string{"Daniel Kahneman"},
+		},
+		{
+			line:    "Thinking, Fast and Slow (Penguin Edition) (Kahneman, Daniel)",
+			title:   "Thinking, Fast and Slow (Penguin Edition)",
+			author:  "Kahneman, Daniel",
+			authors: []string{"Daniel Kahneman"},
+		},
+		{
+			line:    "Judgment under Uncertainty (Daniel Kahneman, Amos Tversky)",
+			title:   "Judgment under Uncertainty",
+			author:  "Daniel Kahneman, Amos Tversky",
+			authors: []string{"Daniel Kahneman", "Amos Tversky"},
+		},
+		{
+			line:    "Judgment under Uncertainty (Kahneman, Daniel; Tversky, Amos)",
+			title:   "Judgment under Uncertainty",
+			author:  "Kahneman, Daniel; Tversky, Amos",
+			authors: []string{"Daniel Kahneman", "Amos Tversky"},
+		},
+		{
+			line:    "Freakonomics (Steven D. Levitt & Stephen J. Dubner)",
+			title:   "Freakonomics",
+			author:  "Steven D. Levitt & Stephen J. Dubner",
+			authors: []string{"Steven D. Levitt", "Stephen J. Dubner"},
+		},
+		{
+			line:    "The Elements of Style (William Strunk Jr., E. B. White, Roger Angell)",
+			title:   "The Elements of Style",
+			author:  "William Strunk Jr., E. B. White, Roger Angell",
+			authors: []string{"William Strunk Jr.", "E. B. White", "Roger Angell"},
+		},
+		{
+			line:    "Преступление и наказание (Достоевский Ф. М.)",
+			title:   "Преступление и наказание",
+			author:  "Достоевский Ф. М.",
+			authors: []string{"Достоевский Ф. М."},
+		},
+		{
+			line:    "Gödel, Escher, Bach (Anniversary Edition (20th)) (Hofstadter, Douglas)",
+			title:   "Gödel, Escher, Bach (Anniversary Edition (20th))",
+			author:  "Hofstadter, Douglas",
+			authors: []string{"Douglas Hofstadter"},
+		},
+		{
+			line:  "My Clippings",
+			title: "My Clippings",
+		},
+		{
+			line:  "Notes (draft",
+			title: "Notes (draft",
+		},
+		{
+			line:  "Notes draft)",
+			title: "Notes draft)",
+		},
+		{
+			line:  "(Anonymous)",
+			title: "(Anonymous)",
+		},
+		{
+			line:    "  Spaces  ( Kahneman ,  Daniel )  ",
+			title:   "Spaces",
+			author:  "Kahneman ,  Daniel",
+			authors: []string{"Daniel Kahneman"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			title, author, authors := BookInfo(tt.line)
+			if title != tt.title || author != tt.author {
+				t.Errorf("BookInfo(%q) = %q, %q, want %q, %q", tt.line, title, author, tt.title, tt.author)
+			}
+			if len(authors) != 0 || len(tt.authors) != 0 {
+				if !reflect.DeepEqual(authors, tt.authors) {
+					t.Errorf("BookInfo(%q) authors = %q, want %q", tt.line, authors, tt.authors)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"strings"
+)
+
+// ClippingLimit removes the message Kindle writes instead of, or after, the
+// text of a highlight once the publisher's export limit is reached, e.g.
+// "<You have reached the clipping limit for this item>". It reports whether
+// the message was found.
+func ClippingLimit(text string, phrases []string) (string, bool) {
+	for _, phrase := range phrases {
+		i := strings.Index(text, phrase)
+		if phrase == "" || i < 0 {
+			continue
+		}
+
+		// the message is always enclosed in "<...>", which keeps quotes that
+		// merely mention the phrase intact
+		start := strings.LastIndex(text[:i], "<")
+		end := strings.Index(text[i:], ">")
+		if start < 0 || end < 0 || strings.ContainsAny(text[start:i+end], "\n>") {
+			continue
+		}
+		end += i + 1
+
+		return strings.TrimSpace(text[:start] + text[end:]), true
+	}
+
+	return text, false
+}
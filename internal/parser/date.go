@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -10,73 +11,69 @@ import (
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
 )
 
-const (
-	debugModeEnabled  = false
-)
-
+// Date parses the date of metaInfo. Kindle writes the wall-clock time of the
+// device without a zone, so it is interpreted in loc.
+//
 // example metaInfo: - Your Highlight Location 1293-1294 | Added on Sunday, December 1, 2013 7:49:48 PM
 func Date(
 	metaInfo string,
-	transMap map[string]model.Translation,
+	transl model.Translation,
+	loc *time.Location,
 ) (time.Time, error) {
-	dateParts := strings.Split(metaInfo, " | ")
+	dateParts := splitMetaInfo(metaInfo)
 	if len(dateParts) < 2 {
-		return time.Time{}, fmt.Errorf("invalid metaInfo: %s", metaInfo)
+		return time.Time{}, fmt.Errorf("%w: %s", ErrInvalidMetaInfo, metaInfo)
 	}
 
 	datePart := strings.TrimSpace(dateParts[len(dateParts)-1])
-	var dateStr string
 
-	for _, v := range transMap {
-		if strings.Contains(datePart, v.AddedOn) {
-			dateParts = strings.Split(datePart, v.AddedOn)
-			if len(dateParts) < 2 {
-				return time.Time{}, fmt.Errorf("invalid date string: %s", dateParts)
-			}
-			dateStr = strings.TrimSpace(dateParts[1])
-			break
-		}
+	dateParts = strings.SplitN(datePart, transl.AddedOn, 2)
+	if len(dateParts) < 2 {
+		return time.Time{}, fmt.Errorf("%w: datePart %s not contains %q", ErrInvalidMetaInfo, datePart, transl.AddedOn)
 	}
 
-	dateStr = strings.ReplaceAll(dateStr, " г. ", " ")
-	dateStr = strings.ReplaceAll(dateStr, " в ", " ")
-
+	dateStr := replaceDateWords(dateParts[1], transl.DateReplacements)
+	dateStr = strings.Join(strings.Fields(dateStr), " ")
 	if dateStr == "" {
-		return time.Time{}, fmt.Errorf("datePart %s not contains date string from translation map", datePart)
+		return time.Time{}, fmt.Errorf("%w: empty date string in %s", ErrInvalidDate, datePart)
 	}
 
-	var (
-		dt            time.Time
-		dateTemplates = []string{
-			"Monday, January 2, 2006 3:04:05 PM",
-			"Monday, January 2, 2006 15:04:05",
-			"Monday, 2 January 2006 15:04:05",
-			"monday, 2 january 2006 15:04:05",
-		}
-	)
-	var err error
-	var errorStr string
-	for _, tmpl := range dateTemplates {
-		dt, err = time.Parse(tmpl, dateStr)
-		if err != nil {
-			errorStr += fmt.Sprintf("parse date string: %s with template: %s, error: %v\n", dateStr, tmpl, err)
-			continue
+	for _, layout := range transl.DateLayouts {
+		var (
+			dt  time.Time
+			err error
+		)
+		if transl.Locale == "" {
+			dt, err = time.ParseInLocation(layout, dateStr, loc)
 		} else {
-			break
+			dt, err = monday.ParseInLocation(layout, dateStr, loc, monday.Locale(transl.Locale))
 		}
-	}
-	for _, tmpl := range dateTemplates {
-		dt, err = monday.Parse(tmpl, dateStr, monday.LocaleRuRU)
-		if err != nil {
-			errorStr += fmt.Sprintf("monday parse date string: %s with template: %s, error: %v\n", dateStr, tmpl, err)
-			continue
-		} else {
-			break
+		if err == nil {
+			return dt, nil
 		}
 	}
-	if dt.IsZero() && debugModeEnabled {
-		fmt.Println(errorStr)
+
+	return time.Time{}, fmt.Errorf("%w: %q matches none of %q", ErrInvalidDate, dateStr, transl.DateLayouts)
+}
+
+// replaceDateWords applies the translation's date replacements, longest
+// words first, e.g. "水曜日" to "" and "午後" to "PM". Replacements are
+// padded with spaces as CJK dates do not separate words.
+func replaceDateWords(dateStr string, replacements map[string]string) string {
+	words := make([]string, 0, len(replacements))
+	for w := range replacements {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if len(words[i]) != len(words[j]) {
+			return len(words[i]) > len(words[j])
+		}
+		return words[i] < words[j]
+	})
+
+	for _, w := range words {
+		dateStr = strings.ReplaceAll(dateStr, w, " "+replacements[w]+" ")
 	}
 
-	return dt, nil
+	return dateStr
 }
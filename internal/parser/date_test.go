@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDate(t *testing.T) {
+	transMap := testTranslations(t)
+
+	tests := []struct {
+		lang     string
+		metaInfo string
+		want     time.Time
+	}{
+		{
+			lang:     "en",
+			metaInfo: "- Your Highlight on page 12 | Location 1293-1294 | Added on Sunday, December 1, 2013 7:49:48 PM",
+			want:     time.Date(2013, time.December, 1, 19, 49, 48, 0, time.UTC),
+		},
+		{
+			lang:     "en",
+			metaInfo: "- Your Highlight on Location 1293-1294 | Added on Sunday, 1 December 2013 19:49:48",
+			want:     time.Date(2013, time.December, 1, 19, 49, 48, 0, time.UTC),
+		},
+		{
+			lang:     "ru",
+			metaInfo: "- Ваш выделенный отрывок на странице 117 | место 1788–1790 | Добавлено: понедельник, 17 августа 2020 г. в 19:25:51",
+			want:     time.Date(2020, time.August, 17, 19, 25, 51, 0, time.UTC),
+		},
+		{
+			lang:     "de",
+			metaInfo: "- Ihre Markierung auf Seite 12 | Position 1293-1294 | Hinzugefügt am Sonntag, 1. Dezember 2013 19:49:48",
+			want:     time.Date(2013, time.December, 1, 19, 49, 48, 0, time.UTC),
+		},
+		{
+			lang:     "fr",
+			metaInfo: "- Votre surlignement sur la page 12 | emplacement 1293-1294 | Ajouté le dimanche 1 décembre 2013 19:49:48",
+			want:     time.Date(2013, time.December, 1, 19, 49, 48, 0, time.UTC),
+		},
+		{
+			lang:     "es",
+			metaInfo: "- Tu subrayado en la página 3 | posición 31-32 | Añadido el lunes, 1 de enero de 2018 17:06:02",
+			want:     time.Date(2018, time.January, 1, 17, 6, 2, 0, time.UTC),
+		},
+		{
+			lang:     "ja",
+			metaInfo: "- 位置No. １２３４-１２３５のハイライト |作成日： 2023年4月5日水曜日 20:11:09",
+			want:     time.Date(2023, time.April, 5, 20, 11, 9, 0, time.UTC),
+		},
+		{
+			lang:     "ja",
+			metaInfo: "- 12ページ|位置No. 1300-1301のハイライト |作成日: 2023年4月5日 水曜日 午後8:11:09",
+			want:     time.Date(2023, time.April, 5, 20, 11, 9, 0, time.UTC),
+		},
+		{
+			lang:     "zh",
+			metaInfo: "- 您在第 12 页（位置 #1234-1235）的标注 | 添加于 2023年4月5日星期三 下午8:11:09",
+			want:     time.Date(2023, time.April, 5, 20, 11, 9, 0, time.UTC),
+		},
+		{
+			lang:     "zh",
+			metaInfo: "- 您在位置 #1234-1235的标注 | 添加于 2023年4月9日星期天 上午8:11:09",
+			want:     time.Date(2023, time.April, 9, 8, 11, 9, 0, time.UTC),
+		},
+		{
+			lang:     "ko",
+			metaInfo: "- 위치 1234-1235의 하이라이트 | 추가된 날짜: 2023년 4월 5일 수요일 오후 8:11:09",
+			want:     time.Date(2023, time.April, 5, 20, 11, 9, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metaInfo, func(t *testing.T) {
+			got, err := Date(normalizeMetaInfo(tt.metaInfo), transMap[tt.lang], time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Date() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateInLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	metaInfo := "- Your Highlight on Location 10 | Added on Sunday, December 1, 2013 7:49:48 PM"
+
+	got, err := Date(metaInfo, testTranslations(t)["en"], loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2013, time.December, 1, 19, 49, 48, 0, loc); !got.Equal(want) {
+		t.Errorf("Date() = %v, want %v", got, want)
+	}
+}
+
+func TestDateErrors(t *testing.T) {
+	transMap := testTranslations(t)
+
+	tests := []struct {
+		metaInfo string
+		want     error
+	}{
+		{
+			metaInfo: "- Your Highlight on Location 10 Added on Sunday, December 1, 2013 7:49:48 PM",
+			want:     ErrInvalidMetaInfo,
+		},
+		{
+			metaInfo: "- Your Highlight on Location 10 | Sunday, December 1, 2013 7:49:48 PM",
+			want:     ErrInvalidMetaInfo,
+		},
+		{
+			metaInfo: "- Your Highlight on Location 10 | Added on ",
+			want:     ErrInvalidDate,
+		},
+		{
+			metaInfo: "- Your Highlight on Location 10 | Added on Funday, December 1, 2013 7:49:48 PM",
+			want:     ErrInvalidDate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metaInfo, func(t *testing.T) {
+			_, err := Date(tt.metaInfo, transMap["en"], time.UTC)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Date() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceDateWords(t *testing.T) {
+	transMap := testTranslations(t)
+
+	tests := []struct {
+		lang string
+		date string
+		want string
+	}{
+		{lang: "ja", date: "2023年4月5日水曜日 20:11:09", want: "2023年4月5日 20:11:09"},
+		{lang: "ja", date: "2023年4月5日 水曜日 午後8:11:09", want: "2023年4月5日 PM 8:11:09"},
+		{lang: "ja", date: "2023年4月5日 水曜日 午前8:11:09", want: "2023年4月5日 AM 8:11:09"},
+		{lang: "zh", date: "2023年4月9日星期天 上午8:11:09", want: "2023年4月9日 AM 8:11:09"},
+		{lang: "zh", date: "2023年4月5日星期三 下午8:11:09", want: "2023年4月5日 PM 8:11:09"},
+		{lang: "ko", date: "2023년 4월 5일 수요일 오후 8:11:09", want: "2023년 4월 5일 PM 8:11:09"},
+		{lang: "en", date: "Sunday, December 1, 2013 7:49:48 PM", want: "Sunday, December 1, 2013 7:49:48 PM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang+" "+tt.date, func(t *testing.T) {
+			got := replaceDateWords(tt.date, transMap[tt.lang].DateReplacements)
+			if got = strings.Join(strings.Fields(got), " "); got != tt.want {
+				t.Errorf("replaceDateWords(%q) = %q, want %q", tt.date, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceDateWordsLongestFirst(t *testing.T) {
+	replacements := map[string]string{"日": "D", "日曜日": ""}
+
+	got := replaceDateWords("1日曜日", replacements)
+	if got = strings.Join(strings.Fields(got), " "); got != "1" {
+		t.Errorf("replaceDateWords() = %q, want %q", got, "1")
+	}
+}
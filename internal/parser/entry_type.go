@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+type EntryType int
+
+const (
+	EntryHighlight EntryType = iota
+	EntryNote
+	EntryBookmark
+)
+
+func (t EntryType) String() string {
+	switch t {
+	case EntryNote:
+		return "note"
+	case EntryBookmark:
+		return "bookmark"
+	default:
+		return "highlight"
+	}
+}
+
+// Type classifies the entry by the words in the first part of metaInfo.
+// Entries that match neither note nor bookmark words are highlights.
+//
+// example metaInfo: - Your Note on Location 1294 | Added on Sunday, December 1, 2013 7:49:48 PM
+func Type(
+	metaInfo string,
+	transl model.Translation,
+) EntryType {
+	kind := strings.ToLower(splitMetaInfo(metaInfo)[0])
+
+	switch {
+	case containsAny(kind, transl.Bookmark):
+		return EntryBookmark
+	case containsAny(kind, transl.Note):
+		return EntryNote
+	default:
+		return EntryHighlight
+	}
+}
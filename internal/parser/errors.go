@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidEntry    = errors.New("invalid entry")
+	ErrEmptyHighlight  = errors.New("empty highlight")
+	ErrUnknownLanguage = errors.New("unknown language")
+	ErrInvalidDate     = errors.New("unparsable date")
+	ErrInvalidMetaInfo = errors.New("malformed metaInfo")
+)
+
+// EntryError is an error about one clippings entry. It wraps one of the
+// Err* values above, so callers can still use errors.Is.
+type EntryError struct {
+	Err   error
+	Entry string
+	// Line is the line number of the entry in the input, when known.
+	Line int
+}
+
+func (e *EntryError) Error() string {
+	head := strings.SplitN(strings.TrimSpace(e.Entry), "\n", 2)[0]
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d (%s): %v", e.Line, head, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %v", head, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"fmt"
 	"errors"
 	"strings"
 	"time"
@@ -9,88 +8,80 @@ import (
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
 )
 
-const (
-	safeFilenameChars = " abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_АБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯабвгдеёжзийклмнопрстуфхцчшщъыьэюя"
-)
-
-var (
-	ErrInvalidEntry   = errors.New("invalid entry")
-	ErrEmptyHighlight = errors.New("empty highlight")
-)
-
 type HighlightData struct {
+	// BookInfo is the title line of the entry as written by Kindle.
+	BookInfo      string
 	BookTitle     string
-	Filename      string
 	BookAuthor    string
+	BookAuthors   []string
+	Type          EntryType
 	Date          time.Time
+	Page          model.Range
+	Location      model.Range
 	HighlightText string
+	// LimitReached is set when Kindle replaced or cut the text because the
+	// clipping limit of the book was reached.
+	LimitReached bool
 }
 
+// ParseClippingsEntry parses one entry of My Clippings.txt. Errors other than
+// ErrInvalidEntry and ErrEmptyHighlight are returned as *EntryError. On
+// ErrInvalidDate the returned data is complete except for the zero Date.
+// Dates are interpreted in loc, the time zone of the device.
 func ParseClippingsEntry(
 	entry string,
 	transMap map[string]model.Translation,
+	loc *time.Location,
 ) (HighlightData, error) {
 	lines := strings.Split(strings.TrimSpace(entry), "\n")
-	if len(lines) < 3 {
+	if len(lines) < 2 {
 		return HighlightData{}, ErrInvalidEntry
 	}
 	bookInfo := lines[0]
-	metaInfo := lines[1]
+	metaInfo := normalizeMetaInfo(lines[1])
 
-	bookTitle := getBookTitle(bookInfo)
-	bookAuthor := getBookAuthor(bookInfo)
+	bookTitle, bookAuthor, bookAuthors := BookInfo(bookInfo)
 
-	noteDate, err := Date(metaInfo, transMap)
+	transl, err := Language(metaInfo, transMap)
 	if err != nil {
-		return HighlightData{}, err
+		return HighlightData{}, &EntryError{Err: err, Entry: entry}
 	}
 
-	highlightText := strings.Join(lines[3:], "\n")
+	noteDate, dateErr := Date(metaInfo, transl, loc)
+	if dateErr != nil && !errors.Is(dateErr, ErrInvalidDate) {
+		return HighlightData{}, &EntryError{Err: dateErr, Entry: entry}
+	}
 
-	if strings.TrimSpace(highlightText) == "" {
+	entryType := Type(metaInfo, transl)
+	page, location := Position(metaInfo, transl)
+
+	var highlightText string
+	if len(lines) > 3 {
+		highlightText = strings.Join(lines[3:], "\n")
+	}
+
+	// bookmarks have no text, everything else must have some
+	if entryType != EntryBookmark && strings.TrimSpace(highlightText) == "" {
 		return HighlightData{}, ErrEmptyHighlight
 	}
 
-	return HighlightData{
+	highlightText, limitReached := ClippingLimit(highlightText, transl.ClippingLimit)
+
+	data := HighlightData{
+		BookInfo:      bookInfo,
 		BookTitle:     bookTitle,
-		Filename:      getValidFilename(bookTitle, bookAuthor),
 		BookAuthor:    bookAuthor,
+		BookAuthors:   bookAuthors,
+		Type:          entryType,
 		Date:          noteDate,
+		Page:          page,
+		Location:      location,
 		HighlightText: highlightText,
-	}, nil
-}
-
-func getBookTitle(s string) string {
-	parts := strings.Split(s, "(")
-	if len(parts) < 2 {
-		return s
-	}
-	return strings.TrimSpace(parts[0])
-}
-
-func getBookAuthor(s string) string {
-	parts := strings.Split(s, "(")
-	if len(parts) < 2 {
-		return s
+		LimitReached:  limitReached,
 	}
-	part := strings.TrimSpace(parts[1])
-
-	return strings.TrimRight(part, ")")
-}
-
-func getValidFilename(bookTitle, bookAuthor string) string {
-	extention := "md"
-	bookAuthor = strings.TrimSpace(bookAuthor)
-	bookTitle = strings.TrimSpace(bookTitle)
-	bookTitleAuthor := fmt.Sprintf("%s - %s", bookTitle, bookAuthor)
-	// clean up the string
-	var builder strings.Builder
-	for _, c := range bookTitleAuthor {
-		if strings.ContainsRune(safeFilenameChars, c) {
-			builder.WriteRune(c)
-		}
+	if dateErr != nil {
+		return data, &EntryError{Err: dateErr, Entry: entry}
 	}
-	bookTitleAuthor = builder.String()
 
-	return fmt.Sprintf("%s.%s", bookTitleAuthor, extention)
+	return data, nil
 }
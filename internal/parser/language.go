@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+// Language finds the translation whose AddedOn phrase appears in metaInfo.
+// Languages are tried in name order, so the result does not depend on map
+// iteration.
+func Language(
+	metaInfo string,
+	transMap map[string]model.Translation,
+) (model.Translation, error) {
+	langs := make([]string, 0, len(transMap))
+	for lang := range transMap {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	for _, lang := range langs {
+		if strings.Contains(metaInfo, transMap[lang].AddedOn) {
+			return transMap[lang], nil
+		}
+	}
+
+	return model.Translation{}, fmt.Errorf("%w: metaInfo %s not contains date string from translation map", ErrUnknownLanguage, metaInfo)
+}
@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+var metaInfoSeparatorRe = regexp.MustCompile(`\s*\|\s*`)
+
+// fullWidthReplacer maps the full-width forms used by CJK Kindles to ASCII.
+var fullWidthReplacer = strings.NewReplacer(
+	"０", "0", "１", "1", "２", "2", "３", "3", "４", "4",
+	"５", "5", "６", "6", "７", "7", "８", "8", "９", "9",
+	"：", ":", "｜", "|", "＃", "#", "－", "-", "～", "-",
+	"（", "(", "）", ")", "，", ",", "　", " ",
+)
+
+// normalizeMetaInfo replaces full-width digits and punctuation so that the
+// rest of the parser only deals with ASCII numbers and separators.
+//
+// example metaInfo: - 位置No. １２３４-１２３５のハイライト ｜作成日： 2023年4月5日水曜日 20:11:09
+func normalizeMetaInfo(metaInfo string) string {
+	return strings.TrimSpace(fullWidthReplacer.Replace(metaInfo))
+}
+
+// splitMetaInfo splits metaInfo on "|", which CJK Kindles write without
+// the surrounding spaces.
+func splitMetaInfo(metaInfo string) []string {
+	return metaInfoSeparatorRe.Split(metaInfo, -1)
+}
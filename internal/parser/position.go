@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+var rangeRe = regexp.MustCompile(`(\d+)(?:\s*[-–—]\s*(\d+))?`)
+
+// Position extracts page and location ranges from metaInfo. PDFs usually
+// only carry a page and books without real page numbers only a location, in
+// which case the missing one is returned as a zero model.Range.
+//
+// Each number is named by the closest page or location word in front of it
+// or, failing that, right after it, as in "12ページ" or "第 12 页（位置 #1234-1235）".
+//
+// example metaInfo: - Your Highlight on page 12 | Location 1293-1294 | Added on Sunday, December 1, 2013 7:49:48 PM
+func Position(
+	metaInfo string,
+	transl model.Translation,
+) (page model.Range, location model.Range) {
+	parts := splitMetaInfo(metaInfo)
+	if len(parts) > 1 {
+		// the last part is always the date
+		parts = parts[:len(parts)-1]
+	}
+
+	for _, part := range parts {
+		lower := strings.ToLower(part)
+		matches := rangeRe.FindAllStringSubmatchIndex(lower, -1)
+
+		for i, m := range matches {
+			r, ok := parseRange(lower[m[0]:m[1]])
+			if !ok {
+				continue
+			}
+
+			prefixStart, suffixEnd := 0, len(lower)
+			if i > 0 {
+				prefixStart = matches[i-1][1]
+			}
+			if i < len(matches)-1 {
+				suffixEnd = matches[i+1][0]
+			}
+			prefix, suffix := lower[prefixStart:m[0]], lower[m[1]:suffixEnd]
+
+			switch closestWord(prefix, suffix, transl) {
+			case "page":
+				page = r
+			case "location":
+				location = r
+			default:
+				if location.IsZero() {
+					// old firmwares write "Loc." or nothing at all: assume a location
+					location = r
+				}
+			}
+		}
+	}
+
+	return page, location
+}
+
+// closestWord returns "page" or "location" depending on which word is the
+// last one in prefix or, when prefix has none, the first one in suffix.
+func closestWord(prefix, suffix string, transl model.Translation) string {
+	pagePos, locPos := lastIndexAny(prefix, transl.Page), lastIndexAny(prefix, transl.Location)
+	switch {
+	case pagePos > locPos:
+		return "page"
+	case locPos > pagePos:
+		return "location"
+	}
+
+	pagePos, locPos = indexAny(suffix, transl.Page), indexAny(suffix, transl.Location)
+	switch {
+	case pagePos >= 0 && (locPos < 0 || pagePos < locPos):
+		return "page"
+	case locPos >= 0:
+		return "location"
+	}
+
+	return ""
+}
+
+func lastIndexAny(s string, words []string) int {
+	res := -1
+	for _, w := range words {
+		if w == "" {
+			continue
+		}
+		if i := strings.LastIndex(s, strings.ToLower(w)); i > res {
+			res = i
+		}
+	}
+
+	return res
+}
+
+func indexAny(s string, words []string) int {
+	res := -1
+	for _, w := range words {
+		if w == "" {
+			continue
+		}
+		if i := strings.Index(s, strings.ToLower(w)); i >= 0 && (res < 0 || i < res) {
+			res = i
+		}
+	}
+
+	return res
+}
+
+// containsAny reports whether s contains any of words, ignoring case.
+func containsAny(s string, words []string) bool {
+	for _, w := range words {
+		if w != "" && strings.Contains(s, strings.ToLower(w)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRange finds the first "1293", "1293-1294" or abbreviated "1293-94"
+// range in s.
+func parseRange(s string) (model.Range, bool) {
+	m := rangeRe.FindStringSubmatch(s)
+	if m == nil {
+		return model.Range{}, false
+	}
+
+	start, err := strconv.Atoi(m[1])
+	if err != nil {
+		return model.Range{}, false
+	}
+
+	end := start
+	if m[2] != "" {
+		endStr := m[2]
+		if len(endStr) < len(m[1]) {
+			endStr = m[1][:len(m[1])-len(endStr)] + endStr
+		}
+		end, err = strconv.Atoi(endStr)
+		if err != nil || end < start {
+			end = start
+		}
+	}
+
+	return model.Range{Start: start, End: end}, true
+}
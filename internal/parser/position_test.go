@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/storage"
+)
+
+// testTranslations returns the built-in languages.
+func testTranslations(t *testing.T) map[string]model.Translation {
+	t.Helper()
+
+	transMap, err := storage.ReadTranslations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return transMap
+}
+
+func TestPosition(t *testing.T) {
+	transMap := testTranslations(t)
+
+	tests := []struct {
+		lang     string
+		metaInfo string
+		page     model.Range
+		location model.Range
+	}{
+		{
+			lang:     "en",
+			metaInfo: "- Your Highlight on page 12 | Location 1293-1294 | Added on Sunday, December 1, 2013 7:49:48 PM",
+			page:     model.Range{Start: 12, End: 12},
+			location: model.Range{Start: 1293, End: 1294},
+		},
+		{
+			lang:     "en",
+			metaInfo: "- Your Highlight on Location 1293-94 | Added on Sunday, December 1, 2013 7:49:48 PM",
+			location: model.Range{Start: 1293, End: 1294},
+		},
+		{
+			lang:     "en",
+			metaInfo: "- Your Highlight on page 5 | Added on Sunday, December 1, 2013 7:49:48 PM",
+			page:     model.Range{Start: 5, End: 5},
+		},
+		{
+			lang:     "en",
+			metaInfo: "- Highlight Loc. 1293-94  | Added on Sunday, December 1, 2013, 07:49 PM",
+			location: model.Range{Start: 1293, End: 1294},
+		},
+		{
+			lang:     "en",
+			metaInfo: "- Your Bookmark 500 | Added on Sunday, December 1, 2013 7:52:48 PM",
+			location: model.Range{Start: 500, End: 500},
+		},
+		{
+			lang:     "ru",
+			metaInfo: "- Ваш выделенный отрывок на странице 117 | место 1788–1790 | Добавлено: понедельник, 17 августа 2020 г. в 19:25:51",
+			page:     model.Range{Start: 117, End: 117},
+			location: model.Range{Start: 1788, End: 1790},
+		},
+		{
+			lang:     "de",
+			metaInfo: "- Ihre Markierung auf Seite 12 | Position 1293-1294 | Hinzugefügt am Sonntag, 1. Dezember 2013 19:49:48",
+			page:     model.Range{Start: 12, End: 12},
+			location: model.Range{Start: 1293, End: 1294},
+		},
+		{
+			lang:     "fr",
+			metaInfo: "- Votre surlignement sur la page 12 | emplacement 1293-1294 | Ajouté le dimanche 1 décembre 2013 19:49:48",
+			page:     model.Range{Start: 12, End: 12},
+			location: model.Range{Start: 1293, End: 1294},
+		},
+		{
+			lang:     "es",
+			metaInfo: "- Tu subrayado en la página 3 | posición 31-32 | Añadido el lunes, 1 de enero de 2018 17:06:02",
+			page:     model.Range{Start: 3, End: 3},
+			location: model.Range{Start: 31, End: 32},
+		},
+		{
+			lang:     "ja",
+			metaInfo: "- 位置No. １２３４-１２３５のハイライト |作成日： 2023年4月5日水曜日 20:11:09",
+			location: model.Range{Start: 1234, End: 1235},
+		},
+		{
+			lang:     "ja",
+			metaInfo: "- 12ページ|位置No. 1300-1301のハイライト |作成日: 2023年4月5日 水曜日 午後8:11:09",
+			page:     model.Range{Start: 12, End: 12},
+			location: model.Range{Start: 1300, End: 1301},
+		},
+		{
+			lang:     "zh",
+			metaInfo: "- 您在第 12 页（位置 #1234-1235）的标注 | 添加于 2023年4月5日星期三 下午8:11:09",
+			page:     model.Range{Start: 12, End: 12},
+			location: model.Range{Start: 1234, End: 1235},
+		},
+		{
+			lang:     "zh",
+			metaInfo: "- 您在位置 #1234-1235的标注 | 添加于 2023年4月5日星期三 上午8:11:09",
+			location: model.Range{Start: 1234, End: 1235},
+		},
+		{
+			lang:     "ko",
+			metaInfo: "- 위치 1234-1235의 하이라이트 | 추가된 날짜: 2023년 4월 5일 수요일 오후 8:11:09",
+			location: model.Range{Start: 1234, End: 1235},
+		},
+		{
+			lang:     "ko",
+			metaInfo: "- 페이지 12 | 위치 1234-1235의 하이라이트 | 추가된 날짜: 2023년 4월 5일 수요일 오후 8:11:09",
+			page:     model.Range{Start: 12, End: 12},
+			location: model.Range{Start: 1234, End: 1235},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metaInfo, func(t *testing.T) {
+			page, location := Position(normalizeMetaInfo(tt.metaInfo), transMap[tt.lang])
+			if page != tt.page || location != tt.location {
+				t.Errorf("Position() = %v, %v, want %v, %v", page, location, tt.page, tt.location)
+			}
+		})
+	}
+}
+
+func TestClosestWord(t *testing.T) {
+	transMap := testTranslations(t)
+
+	tests := []struct {
+		lang           string
+		prefix, suffix string
+		want           string
+	}{
+		{lang: "en", prefix: "- your highlight on page ", want: "page"},
+		{lang: "en", prefix: "- your highlight on page 12 | location ", want: "location"},
+		{lang: "en", prefix: "- your highlight on loc. ", want: "location"},
+		{lang: "en", prefix: "- your bookmark ", suffix: " | added on", want: ""},
+		{lang: "ja", prefix: "- ", suffix: "ページ|位置no. ", want: "page"},
+		{lang: "ja", prefix: "- 12ページ|位置no. ", suffix: "のハイライト ", want: "location"},
+		{lang: "zh", prefix: "- 您在第 ", suffix: " 页(位置 #", want: "page"},
+		{lang: "ko", prefix: "- 위치 ", suffix: "의 하이라이트 ", want: "location"},
+		{lang: "ru", prefix: "- ваш выделенный отрывок на странице ", want: "page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang+" "+tt.prefix, func(t *testing.T) {
+			got := closestWord(tt.prefix, tt.suffix, transMap[tt.lang])
+			if got != tt.want {
+				t.Errorf("closestWord(%q, %q) = %q, want %q", tt.prefix, tt.suffix, got, tt.want)
+			}
+		})
+	}
+}
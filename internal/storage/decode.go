@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+type Encoding string
+
+const (
+	EncodingUTF8        Encoding = "UTF-8"
+	EncodingUTF8BOM     Encoding = "UTF-8 with BOM"
+	EncodingUTF16LE     Encoding = "UTF-16LE"
+	EncodingUTF16BE     Encoding = "UTF-16BE"
+	EncodingWindows1251 Encoding = "Windows-1251"
+)
+
+// sniffSize is how much of the input is looked at to guess the encoding.
+const sniffSize = 4096
+
+// textReplacer turns the no-break spaces into plain ones and drops the
+// invisible characters that Kindle and Windows tools leave in the text. The
+// zero-width (non-)joiners are kept as some scripts and emoji need them.
+var textReplacer = strings.NewReplacer(
+	"\u00A0", " ", // no-break space
+	"\u202F", " ", // narrow no-break space
+	"\u2007", " ", // figure space
+	"\u200B", "", // zero-width space
+	"\u2060", "", // word joiner
+	"\uFEFF", "", // zero-width no-break space, BOM
+)
+
+// NormalizeText puts s into the form used for parsing and hashing: NFC with
+// plain spaces and without zero-width spaces.
+func NormalizeText(s string) string {
+	// ASCII text, like most of the text of notes, is normalised already
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return norm.NFC.String(textReplacer.Replace(s))
+		}
+	}
+
+	return s
+}
+
+// DetectEncoding guesses the encoding of the input from its BOM or, when
+// there is none, from the distribution of low control bytes (UTF-16) and
+// UTF-8 validity. Input that is neither is taken as Windows-1251, the
+// encoding of Russian Windows tools.
+func DetectEncoding(br *bufio.Reader) (Encoding, error) {
+	head, err := br.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8BOM, nil
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE, nil
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE, nil
+	}
+
+	// UTF-16 text in Latin, Greek or Cyrillic script has a high byte of 0x00
+	// to 0x04 in every other byte, control bytes which text in UTF-8 or
+	// Windows-1251 does not have
+	var evenLow, oddLow int
+	for i, b := range head {
+		if b > 0x08 {
+			continue
+		}
+		if i%2 == 0 {
+			evenLow++
+		} else {
+			oddLow++
+		}
+	}
+	if quarter := len(head) / 4; quarter > 0 {
+		switch {
+		case oddLow > quarter && evenLow < oddLow/4:
+			return EncodingUTF16LE, nil
+		case evenLow > quarter && oddLow < evenLow/4:
+			return EncodingUTF16BE, nil
+		}
+	}
+
+	if validUTF8Prefix(head, len(head) == sniffSize) {
+		return EncodingUTF8, nil
+	}
+
+	return EncodingWindows1251, nil
+}
+
+// validUTF8Prefix reports whether b is valid UTF-8, allowing a rune cut off
+// at the end when b is only the beginning of the input.
+func validUTF8Prefix(b []byte, truncated bool) bool {
+	if truncated {
+		for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+			if utf8.Valid(b) {
+				return true
+			}
+			b = b[:len(b)-1]
+		}
+	}
+
+	return utf8.Valid(b)
+}
+
+// decoder returns a reader producing UTF-8 with "\n" line endings.
+func decoder(br *bufio.Reader, enc Encoding) io.Reader {
+	var t transform.Transformer
+	switch enc {
+	case EncodingUTF16LE:
+		t = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+	case EncodingUTF16BE:
+		t = unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder()
+	case EncodingWindows1251:
+		t = charmap.Windows1251.NewDecoder()
+	default:
+		t = unicode.UTF8BOM.NewDecoder()
+	}
+
+	return transform.NewReader(br, transform.Chain(t, lineEndings{}))
+}
+
+// lineEndings turns "\r\n" and lone "\r" into "\n".
+type lineEndings struct {
+	transform.NopResetter
+}
+
+func (lineEndings) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		if nDst == len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+
+		c := src[nSrc]
+		if c == '\r' {
+			if nSrc+1 == len(src) && !atEOF {
+				// need the next byte to tell "\r\n" from "\r"
+				return nDst, nSrc, transform.ErrShortSrc
+			}
+			if nSrc+1 < len(src) && src[nSrc+1] == '\n' {
+				nSrc++
+			}
+			c = '\n'
+		}
+
+		dst[nDst] = c
+		nDst++
+		nSrc++
+	}
+
+	return nDst, nSrc, nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const testEntry = "Преступление и наказание (Достоевский Ф. М.)\r\n" +
+	"- Ваш выделенный отрывок на странице 117 | место 1788–1790 | Добавлено: понедельник, 17 августа 2020 г. в 19:25:51\r\n" +
+	"\r\n" +
+	"Тварь ли я дрожащая или право имею.\r\n" +
+	"==========\r\n"
+
+func encode(t *testing.T, e encoding.Encoding, s string) []byte {
+	t.Helper()
+
+	b, err := e.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestDetectEncoding(t *testing.T) {
+	latin := strings.Repeat("Your Highlight on Location 10-11\r\n", 10)
+	// a Cyrillic rune is cut off at the end of the sniffed bytes
+	longUTF8 := "Д" + strings.Repeat("Добавлено", sniffSize/len("Добавлено")+1)
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  Encoding
+	}{
+		{name: "UTF-8", input: []byte(testEntry), want: EncodingUTF8},
+		{name: "UTF-8 longer than sniffed", input: []byte(longUTF8), want: EncodingUTF8},
+		{name: "UTF-8 with BOM", input: encode(t, unicode.UTF8BOM, testEntry), want: EncodingUTF8BOM},
+		{name: "UTF-16LE with BOM", input: encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), testEntry), want: EncodingUTF16LE},
+		{name: "UTF-16BE with BOM", input: encode(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), testEntry), want: EncodingUTF16BE},
+		{name: "UTF-16LE Latin without BOM", input: encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), latin), want: EncodingUTF16LE},
+		{name: "UTF-16BE Latin without BOM", input: encode(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), latin), want: EncodingUTF16BE},
+		{name: "UTF-16LE Cyrillic without BOM", input: encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), testEntry), want: EncodingUTF16LE},
+		{name: "Windows-1251", input: encode(t, charmap.Windows1251, testEntry), want: EncodingWindows1251},
+		{name: "empty", input: nil, want: EncodingUTF8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := bufio.NewReaderSize(bytes.NewReader(tt.input), sniffSize)
+			got, err := DetectEncoding(br)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectEncoding() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectEncodingError(t *testing.T) {
+	br := bufio.NewReaderSize(iotest.ErrReader(io.ErrUnexpectedEOF), sniffSize)
+	if _, err := DetectEncoding(br); err == nil {
+		t.Error("DetectEncoding() succeeded on a failing reader")
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "a\r\nb\r\n", want: "a\nb\n"},
+		{input: "a\rb\r", want: "a\nb\n"},
+		{input: "a\nb", want: "a\nb"},
+		{input: "a\r\r\nb", want: "a\n\nb"},
+		{input: "\r", want: "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, _, err := transform.String(lineEndings{}, tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Transform(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+
+			// one byte at a time, "\r" and "\n" end up in different buffers
+			r := transform.NewReader(iotest.OneByteReader(strings.NewReader(tt.input)), lineEndings{})
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("Transform(%q) byte by byte = %q, want %q", tt.input, b, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineEndingsShortSrc(t *testing.T) {
+	dst := make([]byte, 10)
+
+	nDst, nSrc, err := lineEndings{}.Transform(dst, []byte("a\r"), false)
+	if err != transform.ErrShortSrc || nDst != 1 || nSrc != 1 {
+		t.Errorf("Transform() = %d, %d, %v, want 1, 1, %v", nDst, nSrc, err, transform.ErrShortSrc)
+	}
+
+	nDst, nSrc, err = lineEndings{}.Transform(dst, []byte("\r\nb"), false)
+	if err != nil || string(dst[:nDst]) != "\nb" || nSrc != 3 {
+		t.Errorf("Transform() = %q, %d, %v, want %q, 3, nil", dst[:nDst], nSrc, err, "\nb")
+	}
+
+	nDst, nSrc, err = lineEndings{}.Transform(dst[:1], []byte("a\r\nb"), true)
+	if err != transform.ErrShortDst || nDst != 1 || nSrc != 1 {
+		t.Errorf("Transform() = %d, %d, %v, want 1, 1, %v", nDst, nSrc, err, transform.ErrShortDst)
+	}
+}
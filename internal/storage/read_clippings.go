@@ -2,8 +2,9 @@ package storage
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 	"strings"
 )
 
@@ -11,36 +12,121 @@ const (
 	separator = "=========="
 )
 
-func ReadRawClippings(inputFile string) ([]string, error) {
-	fd, err := os.Open(inputFile)
-	if err != nil {
-		return nil, fmt.Errorf("open input file: %w", err)
-	}
-	defer fd.Close()
+// RawEntry is the text between two separators of My Clippings.txt.
+type RawEntry struct {
+	Text string
+	// Offset is the byte offset of the first line of the entry in the
+	// decoded input.
+	Offset int64
+	// Line is the 1-based number of the first line of the entry.
+	Line int
+}
+
+// ClippingsReader reads My Clippings.txt one entry at a time, so memory use
+// depends on the longest entry rather than on the size of the file. The
+// input is transcoded to UTF-8 (see DetectEncoding) and every line is put
+// through NormalizeText.
+//
+//	cr := storage.NewClippingsReader(fd)
+//	for cr.Next() {
+//		entry := cr.Entry()
+//		...
+//	}
+//	if err := cr.Err(); err != nil {
+//		...
+//	}
+type ClippingsReader struct {
+	src    *bufio.Reader
+	br     *bufio.Reader
+	enc    Encoding
+	entry  RawEntry
+	err    error
+	offset int64
+	line   int
+}
+
+func NewClippingsReader(r io.Reader) *ClippingsReader {
+	return &ClippingsReader{src: bufio.NewReaderSize(r, sniffSize)}
+}
 
-	br := bufio.NewReader(fd)
-	r, _, err := br.ReadRune()
-	if err != nil {
-		return nil, fmt.Errorf("read rune: %w", err)
+// Encoding returns the encoding detected by the first call to Next.
+func (c *ClippingsReader) Encoding() Encoding {
+	return c.enc
+}
+
+// Next advances to the next entry, which is then available through Entry.
+// It returns false at the end of the input or on error.
+func (c *ClippingsReader) Next() bool {
+	if c.err != nil {
+		return false
 	}
 
-	if r != '\uFEFF' {
-		err = br.UnreadRune() // Not a BOM -- put the rune back
+	if c.br == nil {
+		enc, err := DetectEncoding(c.src)
 		if err != nil {
-			return nil, fmt.Errorf("unread rune: %w", err)
+			c.err = fmt.Errorf("detect encoding: %w", err)
+			return false
 		}
+		c.enc = enc
+		c.br = bufio.NewReader(decoder(c.src, enc))
 	}
 
-	scanner := bufio.NewScanner(br)
-	var content strings.Builder
-	for scanner.Scan() {
-		content.WriteString(scanner.Text())
-		content.WriteString("\n")
+	var (
+		content strings.Builder
+		start   RawEntry
+	)
+
+	for {
+		line, err := c.br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			c.err = fmt.Errorf("read line %d: %w", c.line+1, err)
+			return false
+		}
+
+		if line != "" {
+			if content.Len() == 0 {
+				start = RawEntry{Offset: c.offset, Line: c.line + 1}
+			}
+			c.offset += int64(len(line))
+			c.line++
+		}
+
+		text := NormalizeText(strings.TrimSuffix(line, "\n"))
+		if strings.TrimSpace(text) == separator {
+			start.Text = content.String()
+			c.entry = start
+			return true
+		}
+
+		if line != "" {
+			content.WriteString(text)
+			content.WriteString("\n")
+		}
+
+		if errors.Is(err, io.EOF) {
+			c.err = io.EOF
+			if strings.TrimSpace(content.String()) == "" {
+				return false
+			}
+
+			// the last entry is not followed by a separator
+			start.Text = content.String()
+			c.entry = start
+			return true
+		}
 	}
+}
 
-	contentStr := content.String()
+// Entry returns the entry read by the last call to Next.
+func (c *ClippingsReader) Entry() RawEntry {
+	return c.entry
+}
 
-	entries := strings.Split(contentStr, separator)
+// Err returns the first error met by Next, if any.
+func (c *ClippingsReader) Err() error {
+	if errors.Is(c.err, io.EOF) {
+		return nil
+	}
 
-	return entries, nil
+	return c.err
 }
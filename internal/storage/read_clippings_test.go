@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func readEntries(t *testing.T, r io.Reader) []RawEntry {
+	t.Helper()
+
+	var entries []RawEntry
+	cr := NewClippingsReader(r)
+	for cr.Next() {
+		entries = append(entries, cr.Entry())
+	}
+	if err := cr.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+func TestClippingsReader(t *testing.T) {
+	first := "Book (Author)\n- Your Highlight on Location 10-11 | Added on Sunday, December 1, 2013 7:49:48 PM\n\nFirst\n"
+	second := "Book (Author)\n- Your Note on Location 11 | Added on Sunday, December 1, 2013 7:51:48 PM\n\nSecond\nline\n"
+
+	tests := []struct {
+		name  string
+		input string
+		want  []RawEntry
+	}{
+		{
+			name:  "separated",
+			input: first + separator + "\n" + second + separator + "\n",
+			want: []RawEntry{
+				{Text: first, Offset: 0, Line: 1},
+				{Text: second, Offset: int64(len(first) + len(separator) + 1), Line: 6},
+			},
+		},
+		{
+			name:  "no separator after the last entry",
+			input: first + separator + "\n" + strings.TrimSuffix(second, "\n"),
+			want: []RawEntry{
+				{Text: first, Offset: 0, Line: 1},
+				{Text: second, Offset: int64(len(first) + len(separator) + 1), Line: 6},
+			},
+		},
+		{
+			name:  "Windows line endings",
+			input: strings.ReplaceAll(first+separator+"\n"+second+separator+"\n", "\n", "\r\n"),
+			want: []RawEntry{
+				{Text: first, Offset: 0, Line: 1},
+				{Text: second, Offset: int64(len(first) + len(separator) + 1), Line: 6},
+			},
+		},
+		{
+			name:  "blank lines after the last separator",
+			input: first + separator + "\n\n  \n",
+			want: []RawEntry{
+				{Text: first, Offset: 0, Line: 1},
+			},
+		},
+		{
+			name:  "empty entry",
+			input: separator + "\n" + first + separator + "\n",
+			want: []RawEntry{
+				{Text: "", Offset: 0, Line: 1},
+				{Text: first, Offset: int64(len(separator) + 1), Line: 2},
+			},
+		},
+		{
+			name:  "separator with spaces",
+			input: first + " " + separator + " \n" + second,
+			want: []RawEntry{
+				{Text: first, Offset: 0, Line: 1},
+				{Text: second, Offset: int64(len(first) + len(separator) + 4), Line: 6},
+			},
+		},
+		{
+			name:  "empty input",
+			input: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readEntries(t, iotest.HalfReader(strings.NewReader(tt.input)))
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d entries, want %d: %q", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClippingsReaderEncodings(t *testing.T) {
+	want := strings.ReplaceAll(strings.TrimSuffix(testEntry, separator+"\r\n"), "\r\n", "\n")
+
+	tests := []struct {
+		name  string
+		input []byte
+		enc   Encoding
+	}{
+		{name: "UTF-8", input: []byte(testEntry), enc: EncodingUTF8},
+		{name: "UTF-8 with BOM", input: encode(t, unicode.UTF8BOM, testEntry), enc: EncodingUTF8BOM},
+		{name: "UTF-16LE", input: encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), testEntry), enc: EncodingUTF16LE},
+		{name: "UTF-16BE without BOM", input: encode(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), testEntry), enc: EncodingUTF16BE},
+		{name: "Windows-1251", input: encode(t, charmap.Windows1251, testEntry), enc: EncodingWindows1251},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := NewClippingsReader(bytes.NewReader(tt.input))
+			if !cr.Next() {
+				t.Fatalf("no entry read: %v", cr.Err())
+			}
+			if got := cr.Entry().Text; got != want {
+				t.Errorf("entry = %q, want %q", got, want)
+			}
+			if cr.Encoding() != tt.enc {
+				t.Errorf("Encoding() = %s, want %s", cr.Encoding(), tt.enc)
+			}
+			if cr.Next() {
+				t.Errorf("more than one entry read: %q", cr.Entry().Text)
+			}
+		})
+	}
+}
+
+func TestClippingsReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader(testEntry), iotest.ErrReader(io.ErrUnexpectedEOF))
+
+	cr := NewClippingsReader(r)
+	for cr.Next() {
+	}
+	if cr.Err() == nil {
+		t.Error("Err() = nil after a read error")
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+)
+
+const (
+	configDir  = "kindle-highlights-to-obsidian"
+	configFile = "config.json"
+)
+
+// DefaultConfigPath returns the path of the config file in the user's
+// configuration directory, e.g. ~/.config/kindle-highlights-to-obsidian/config.json.
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, configDir, configFile)
+}
+
+// ReadConfig reads the config file at path. A missing file at the default
+// path is not an error and yields an empty config.
+func ReadConfig(path string) (model.Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultConfigPath()
+	}
+
+	if path == "" {
+		return model.Config{}, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return model.Config{}, nil
+		}
+		return model.Config{}, fmt.Errorf("read config file: %w", err)
+	}
+
+	var cfg model.Config
+	err = json.Unmarshal(content, &cfg)
+	if err != nil {
+		return model.Config{}, fmt.Errorf("unmarshal config: %w", err)
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return model.Config{}, fmt.Errorf("validate config: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -3,22 +3,25 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"strings"
 
 	"github.com/nsr888/kindle-highlights-to-obsidian/internal/model"
+	"github.com/nsr888/kindle-highlights-to-obsidian/languages"
 )
 
+// ReadTranslations reads the languages built into the binary, keyed by the
+// name of their file, e.g. "en".
 func ReadTranslations() (map[string]model.Translation, error) {
-	files, err := filepath.Glob("languages/*.json")
+	files, err := fs.Glob(languages.FS, "*.json")
 	if err != nil {
 		return nil, fmt.Errorf("glob failed: %w", err)
 	}
 
 	translationMap := make(map[string]model.Translation, len(files))
 	for _, file := range files {
-		content, err := os.ReadFile(file)
+		content, err := fs.ReadFile(languages.FS, file)
 		if err != nil {
 			return nil, fmt.Errorf("read translation file: %w", err)
 		}
@@ -33,8 +36,7 @@ func ReadTranslations() (map[string]model.Translation, error) {
 			return nil, fmt.Errorf("validate: %w", err)
 		}
 
-		b := filepath.Base(file)
-		lang := strings.TrimSuffix(b, filepath.Ext(b))
+		lang := strings.TrimSuffix(file, path.Ext(file))
 		translationMap[lang] = translation
 	}
 
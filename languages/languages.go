@@ -0,0 +1,10 @@
+// Package languages holds the descriptions of the Kindle languages built into
+// the binary.
+package languages
+
+import "embed"
+
+// FS holds one file per language named after it, e.g. "en.json".
+//
+//go:embed *.json
+var FS embed.FS
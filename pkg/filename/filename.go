@@ -0,0 +1,139 @@
+// Package filename builds file names for notes that are valid on Windows,
+// macOS and Linux and can be linked to from Obsidian.
+package filename
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	extension = ".md"
+
+	// maxBytes limits the name without extension, leaving room for the
+	// extension and the suffix of Disambiguate within the 255 bytes most
+	// file systems allow.
+	maxBytes = 200
+
+	// illegal are not allowed in file names on Windows or macOS, or break
+	// Obsidian links.
+	illegal = `<>:"/\|?*#^[]`
+)
+
+// reserved are device names Windows does not allow as file names, with any
+// extension.
+var reserved = map[string]struct{}{
+	"CON": {}, "PRN": {}, "AUX": {}, "NUL": {},
+	"COM1": {}, "COM2": {}, "COM3": {}, "COM4": {}, "COM5": {}, "COM6": {}, "COM7": {}, "COM8": {}, "COM9": {},
+	"LPT1": {}, "LPT2": {}, "LPT3": {}, "LPT4": {}, "LPT5": {}, "LPT6": {}, "LPT7": {}, "LPT8": {}, "LPT9": {},
+}
+
+type Options struct {
+	// Transliterate writes Latin, Cyrillic and Greek letters in ASCII.
+	// Letters of other scripts are kept.
+	Transliterate bool
+}
+
+// Build returns "title - author.md", or "title.md" without author, with the
+// characters that are not allowed in file names or links replaced by
+// spaces. Letters of all scripts are kept. fallback is used when nothing is
+// left of title and author.
+func Build(title, author, fallback string, opts Options) string {
+	name := clean(title)
+	if a := clean(author); a != "" {
+		name += " - " + a
+	}
+
+	if opts.Transliterate {
+		name = Transliterate(name)
+	}
+
+	name = truncate(name, maxBytes)
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		name = fallback
+	}
+
+	// Windows takes "CON.txt.md" for the device as well
+	base, _, _ := strings.Cut(name, ".")
+	if _, ok := reserved[strings.ToUpper(strings.TrimRight(base, " "))]; ok {
+		name = base + "_" + name[len(base):]
+	}
+
+	return name + extension
+}
+
+// clean replaces illegal and control characters by spaces and collapses
+// whitespace. Leading dots are dropped, as they hide files.
+func clean(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(illegal, r) {
+			return ' '
+		}
+		return r
+	}, norm.NFC.String(s))
+
+	s = strings.Join(strings.Fields(s), " ")
+
+	return strings.TrimLeft(s, ". ")
+}
+
+// truncate cuts s to at most n bytes at a word boundary if there is one in
+// the last quarter, at a rune boundary otherwise.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	cut := n
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if i := strings.LastIndexByte(s[:cut], ' '); i > n*3/4 {
+		cut = i
+	}
+
+	return strings.TrimSpace(s[:cut])
+}
+
+// Disambiguate adds the beginning of id to name, for books whose names are
+// the same: "title - author (e03f99ee).md".
+func Disambiguate(name, id string) string {
+	if len(id) > 8 {
+		id = id[:8]
+	}
+
+	return fmt.Sprintf("%s (%s)%s", strings.TrimSuffix(name, extension), id, extension)
+}
+
+// legacyChars are the characters the file names of earlier versions kept.
+const legacyChars = " abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_АБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯабвгдеёжзийклмнопрстуфхцчшщъыьэюя"
+
+// Legacy returns the file name earlier versions used for the book with the
+// title line info, to find their notes. They split info at the first "(",
+// used info as title and author when it had none and kept only ASCII letters
+// and digits and Russian letters:
+//
+//	"Thinking, Fast and Slow (Penguin Edition) (Kahneman, Daniel)" → "Thinking Fast and Slow - Penguin Edition.md"
+//	"Title" → "Title - Title.md"
+func Legacy(info string) string {
+	title, author := info, info
+	if parts := strings.Split(info, "("); len(parts) > 1 {
+		title = parts[0]
+		author = strings.TrimRight(strings.TrimSpace(parts[1]), ")")
+	}
+	name := fmt.Sprintf("%s - %s", strings.TrimSpace(title), strings.TrimSpace(author))
+
+	var sb strings.Builder
+	for _, c := range name {
+		if strings.ContainsRune(legacyChars, c) {
+			sb.WriteRune(c)
+		}
+	}
+
+	return sb.String() + extension
+}
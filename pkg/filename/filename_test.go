@@ -0,0 +1,115 @@
+package filename
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLegacy(t *testing.T) {
+	tests := []struct {
+		info string
+		want string
+	}{
+		{"Thinking, Fast and Slow (Kahneman, Daniel)", "Thinking Fast and Slow - Kahneman Daniel.md"},
+		{"Thinking, Fast and Slow (Penguin Edition) (Kahneman, Daniel)", "Thinking Fast and Slow - Penguin Edition.md"},
+		{"Title", "Title - Title.md"},
+		{"\ufeffВойна и мир (Толстой Лев)\r", "Война и мир - Толстой Лев.md"},
+		{"Café: <x>? (Zoë)", "Caf x - Zo.md"},
+	}
+
+	for _, tt := range tests {
+		if got := Legacy(tt.info); got != tt.want {
+			t.Errorf("Legacy(%q) = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name          string
+		title, author string
+		translit      bool
+		want          string
+	}{
+		{name: "title and author", title: "Thinking, Fast and Slow", author: "Kahneman, Daniel", want: "Thinking, Fast and Slow - Kahneman, Daniel.md"},
+		{name: "no author", title: "Notes", want: "Notes.md"},
+		{name: "illegal characters", title: `Re: <What?> "Why" / How*`, author: "A|B", want: "Re What Why How - A B.md"},
+		{name: "link characters", title: "C# [Basics] ^1", want: "C Basics 1.md"},
+		{name: "leading and trailing dots", title: "...and then.", author: "", want: "and then.md"},
+		{name: "Cyrillic", title: "Преступление и наказание", author: "Достоевский Ф. М.", want: "Преступление и наказание - Достоевский Ф. М.md"},
+		{name: "Cyrillic transliterated", title: "Преступление и наказание", author: "Достоевский Ф. М.", translit: true, want: "Prestuplenie i nakazanie - Dostoevskiy F. M.md"},
+		{name: "Ukrainian transliterated", title: "Їжак і ґава", author: "Євген", translit: true, want: "Yizhak i gava - Yevgen.md"},
+		{name: "Greek", title: "Η Οδύσσεια", author: "Όμηρος", want: "Η Οδύσσεια - Όμηρος.md"},
+		{name: "Greek transliterated", title: "Η Οδύσσεια", author: "Όμηρος", translit: true, want: "I Odysseia - Omiros.md"},
+		{name: "CJK", title: "吾輩は猫である", author: "夏目漱石", want: "吾輩は猫である - 夏目漱石.md"},
+		{name: "CJK transliterated", title: "吾輩は猫である", author: "夏目漱石", translit: true, want: "吾輩は猫である - 夏目漱石.md"},
+		{name: "Korean full-width punctuation", title: "채식주의자：소설", author: "한강", want: "채식주의자：소설 - 한강.md"},
+		{name: "reserved", title: "CON", want: "CON_.md"},
+		{name: "reserved lower case", title: "nul", want: "nul_.md"},
+		{name: "reserved with extension", title: "CON.txt", want: "CON_.txt.md"},
+		{name: "reserved with dots", title: "Aux. Notes", want: "Aux_. Notes.md"},
+		{name: "reserved prefix", title: "Console", want: "Console.md"},
+		{name: "reserved with author", title: "PRN", author: "Anonymous", want: "PRN - Anonymous.md"},
+		{name: "all punctuation", title: "???", author: "<>", want: "e03f99ee8b5f.md"},
+		{name: "dots only", title: "...", want: "e03f99ee8b5f.md"},
+		{name: "empty", want: "e03f99ee8b5f.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(tt.title, tt.author, "e03f99ee8b5f", Options{Transliterate: tt.translit})
+			if got != tt.want {
+				t.Errorf("Build(%q, %q) = %q, want %q", tt.title, tt.author, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTruncates(t *testing.T) {
+	tests := []struct {
+		name   string
+		title  string
+		prefix string
+	}{
+		{name: "at a word boundary", title: strings.Repeat("word ", 60), prefix: strings.Repeat("word ", 39) + "word.md"},
+		{name: "Cyrillic at a rune boundary", title: strings.Repeat("ж", 150), prefix: strings.Repeat("ж", maxBytes/2) + ".md"},
+		{name: "CJK at a rune boundary", title: strings.Repeat("猫", 100), prefix: strings.Repeat("猫", maxBytes/3) + ".md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Build(tt.title, "", "id", Options{})
+			if len(got) > maxBytes+len(extension) || !utf8.ValidString(got) {
+				t.Errorf("Build() = %q, %d bytes, want at most %d bytes of UTF-8", got, len(got), maxBytes+len(extension))
+			}
+			if got != tt.prefix {
+				t.Errorf("Build() = %q, want %q", got, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Война и мир", "Voyna i mir"},
+		{"ЖЁЛТЫЙ Щит", "ZhELTYY Shchit"},
+		{"Їжак, ґава, Євген, Іван", "Yizhak, gava, Yevgen, Ivan"},
+		{"Беларуская мова: ў", "Belaruskaya mova: w"},
+		{"Αλφάβητο", "Alfavito"},
+		{"Θεός ψυχή", "Theos psychi"},
+		{"Café Zoë Ångström", "Cafe Zoe Angstrom"},
+		{"Straße, Œuvre, Łódź, Ærø", "Strasse, Oeuvre, Lodz, Aero"},
+		{"東京 서울", "東京 서울"},
+		{"ASCII only 123", "ASCII only 123"},
+	}
+
+	for _, tt := range tests {
+		if got := Transliterate(tt.in); got != tt.want {
+			t.Errorf("Transliterate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
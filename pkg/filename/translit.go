@@ -0,0 +1,76 @@
+package filename
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// translit holds the ASCII spelling of letters that are not a Latin letter
+// with diacritics: Cyrillic letters of Russian, Ukrainian and Belarusian,
+// Greek letters and Latin letters without decomposition. Upper case letters
+// are derived from the lower case ones.
+var translit = map[rune]string{
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "w",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	// Latin
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ł': "l", 'đ': "d", 'ð': "d",
+	'þ': "th", 'ħ': "h", 'ı': "i", 'ŋ': "ng",
+}
+
+// Transliterate writes the Latin, Cyrillic and Greek letters of s in ASCII,
+// e.g. "Преступление и наказание" as "Prestuplenie i nakazanie" and "Café"
+// as "Cafe". Letters of other scripts are kept as they are.
+func Transliterate(s string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFC.String(s) {
+		if t, ok := transliterate(r); ok {
+			sb.WriteString(t)
+			continue
+		}
+
+		// letters of other scripts lose more than an accent, e.g. "で" its
+		// voicing mark, and Hangul syllables fall apart into jamo
+		if !unicode.In(r, unicode.Latin, unicode.Greek, unicode.Cyrillic) {
+			sb.WriteRune(r)
+			continue
+		}
+
+		// "é" is "e" followed by a combining accent
+		for _, d := range norm.NFD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			if t, ok := transliterate(d); ok {
+				sb.WriteString(t)
+			} else {
+				sb.WriteRune(d)
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// transliterate returns the ASCII spelling of r from translit, capitalised
+// for upper case letters: "Ж" is "Zh".
+func transliterate(r rune) (string, bool) {
+	lower := unicode.ToLower(r)
+	t, ok := translit[lower]
+	if !ok || lower == r || t == "" {
+		return t, ok
+	}
+
+	return strings.ToUpper(t[:1]) + t[1:], true
+}
@@ -0,0 +1,39 @@
+package hashs
+
+// EditSimilarity returns 1 minus the edit distance of a and b after
+// NormalizeWords, relative to the longer text: 1 for equal texts, about 0.9
+// for a word fixed in a sentence and below that for texts differing in a
+// word like "not". Unlike SimHash it is exact, but quadratic in the length.
+func EditSimilarity(a, b string) float64 {
+	ra, rb := []rune(NormalizeWords(a)), []rune(NormalizeWords(b))
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the number of runes to insert, delete or replace to
+// turn a into b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,72 @@
+package hashs
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingleSize is the number of runes in the shingles SimHash is built from.
+// Short shingles keep small edits like a fixed typo from changing many bits.
+const shingleSize = 3
+
+// NormalizeWords lowercases s and drops punctuation, symbols and quote
+// styles, leaving the words separated by single spaces.
+func NormalizeWords(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, s)
+
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Fingerprint returns the hash of s after NormalizeWords, equal for texts
+// that differ only in case, punctuation, quotes or whitespace.
+func Fingerprint(s string) string {
+	return FNV64a(NormalizeWords(s))
+}
+
+// SimHash returns the 64 bit SimHash of s after NormalizeWords, built from
+// its character shingles. Similar texts have hashes differing in few bits.
+func SimHash(s string) uint64 {
+	runes := []rune(NormalizeWords(s))
+	if len(runes) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	for i := 0; i == 0 || i+shingleSize <= len(runes); i++ {
+		end := min(i+shingleSize, len(runes))
+
+		h := fnv.New64a()
+		h.Write([]byte(string(runes[i:end])))
+		sum := h.Sum64()
+
+		for b := range weights {
+			if sum&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+
+	var res uint64
+	for b, w := range weights {
+		if w > 0 {
+			res |= 1 << b
+		}
+	}
+
+	return res
+}
+
+// Similarity returns the share of equal bits of two SimHashes, 1 for equal
+// hashes and about 0.5 for unrelated texts.
+func Similarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
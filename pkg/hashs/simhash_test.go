@@ -0,0 +1,95 @@
+package hashs
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"The map is the territory.", "the map is the territory", true},
+		{"“Quoted,” he said — twice.", `"Quoted" he said twice`, true},
+		{"Line\nbreak", "Line  break", true},
+		{"The map is not the territory.", "The map is the territory.", false},
+		{"lazy dog", "lazy cat", false},
+	}
+
+	for _, tt := range tests {
+		if same := Fingerprint(tt.a) == Fingerprint(tt.b); same != tt.same {
+			t.Errorf("Fingerprint(%q) == Fingerprint(%q) is %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	if SimHash("") != 0 {
+		t.Errorf("SimHash of empty text is not 0")
+	}
+	if SimHash("The Map, is the territory!") != SimHash("the map is the territory") {
+		t.Errorf("SimHash depends on case or punctuation")
+	}
+
+	// SimHash only tells related from unrelated texts: it overrates
+	// negations, see EditSimilarity
+	related := Similarity(SimHash("The map is not the territory."), SimHash("The map is the territory."))
+	typo := Similarity(SimHash("The quick brown fox jumps over teh lazy dog"), SimHash("The quick brown fox jumps over the lazy dog"))
+	unrelated := Similarity(SimHash("The quick brown fox jumps over the lazy dog"), SimHash("Completely different words in another sentence"))
+	if related < simHashTestScreen || typo < simHashTestScreen {
+		t.Errorf("related texts below %v: %v, %v", simHashTestScreen, related, typo)
+	}
+	if unrelated >= simHashTestScreen {
+		t.Errorf("unrelated texts at %v, want below %v", unrelated, simHashTestScreen)
+	}
+}
+
+// simHashTestScreen is the SimHash similarity the notes are screened with.
+const simHashTestScreen = 0.7
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want float64
+	}{
+		{0, 0, 1},
+		{0, ^uint64(0), 0},
+		{0xFF, 0, 1 - 8.0/64},
+		{0xF0F0, 0x0F0F, 1 - 16.0/64},
+	}
+
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("Similarity(%x, %x) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestEditSimilarity(t *testing.T) {
+	const threshold = 0.9
+
+	tests := []struct {
+		name  string
+		a, b  string
+		above bool
+	}{
+		{"equal", "The map is the territory.", "The map is the territory.", true},
+		{"punctuation", "self-evident, that all men", "self evident that all men", true},
+		{"typo", "The quick brown fox jumps over teh lazy dog", "The quick brown fox jumps over the lazy dog", true},
+		{"word fixed", "The quick brown fox jumps over the lazy dog", "The quick brown fox jumps over the lazy cat", true},
+		{"negation", "The map is not the territory.", "The map is the territory.", false},
+		{"antonym", "It was the best of times", "It was the worst of times", false},
+		{"prefix", "impossible", "possible", false},
+		{"unrelated", "alpha beta gamma", "completely unrelated words", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := EditSimilarity(tt.a, tt.b)
+			if (s >= threshold) != tt.above {
+				t.Errorf("EditSimilarity(%q, %q) = %v, want above %v: %v", tt.a, tt.b, s, threshold, tt.above)
+			}
+			if r := EditSimilarity(tt.b, tt.a); r != s {
+				t.Errorf("EditSimilarity is not symmetric: %v, %v", s, r)
+			}
+		})
+	}
+}
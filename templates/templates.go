@@ -0,0 +1,10 @@
+// Package templates holds the note templates built into the binary.
+package templates
+
+import "embed"
+
+// FS holds the template sets, one file per set named after it, e.g.
+// "obsidian.tmpl", and in "partials" the templates all sets can use.
+//
+//go:embed *.tmpl partials/*.tmpl
+var FS embed.FS